@@ -1,18 +1,27 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 
 	"cortex-backend/internal/discovery"
 	"cortex-backend/internal/auth"
 	"cortex-backend/internal/errors"
+	"cortex-backend/internal/outbound"
 	"cortex-backend/internal/persistence"
 	"cortex-backend/internal/queue"
 	"cortex-backend/internal/risk"
 	"cortex-backend/internal/scanner"
+	"cortex-backend/internal/scanning"
+	"cortex-backend/internal/scope"
+	"cortex-backend/internal/tasks"
 	"cortex-backend/internal/validation"
+	"cortex-backend/pkg/models"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -37,6 +46,7 @@ type ScanResponse struct {
 
 type VerifyRequest struct {
 	Domain string `json:"domain"`
+	Method string `json:"method"` // Optional; all methods are tried when empty
 }
 
 type CreateDomainRequest struct {
@@ -69,6 +79,9 @@ func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
 		// Auto-initialize if not exists, but block scan
 		if err != nil {
 			s.Repo.GetOrCreateDomain(ctx, orgID.String(), req.Domain)
+		} else if domain.ScansSuspended {
+			errors.WriteError(w, http.StatusForbidden, errors.ErrCodeDomainNotVerified, "Scans suspended: domain ownership could not be re-verified. Please verify the domain again.")
+			return
 		}
 		
 		errors.WriteError(w, http.StatusForbidden, errors.ErrCodeDomainNotVerified, "Domain not verified. Please verify ownership via DNS TXT record first.")
@@ -129,34 +142,92 @@ func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	success, err := discovery.VerifyDomain(req.Domain, domain.VerificationToken)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Verification check failed: %v", err), http.StatusInternalServerError)
-		return
+	methods := append(append([]string{}, discovery.VerificationMethods...), discovery.MethodParentDomain)
+	if req.Method != "" {
+		valid := false
+		for _, m := range methods {
+			valid = valid || m == req.Method
+		}
+		if !valid {
+			errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, "Unsupported verification method")
+			return
+		}
+		methods = []string{req.Method}
+	}
+
+	verifiedBy := ""
+	for _, method := range methods {
+		var success bool
+		if method == discovery.MethodParentDomain {
+			success = s.hasVerifiedParent(ctx, orgID.String(), req.Domain)
+		} else {
+			success, err = discovery.CheckVerification(method, req.Domain, domain.VerificationToken)
+			if err != nil && req.Method != "" {
+				http.Error(w, fmt.Sprintf("Verification check failed: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+		if success {
+			verifiedBy = method
+			break
+		}
 	}
 
 	ctx = auth.WithRequest(ctx, r)
 	
-	if success {
-		s.Repo.UpdateDomainVerification(ctx, domain.ID.String(), true)
+	if verifiedBy != "" {
+		s.Repo.UpdateDomainVerification(ctx, domain.ID.String(), true, verifiedBy)
 		
 		// Audit log: Domain verification
 		auth.LogAction(ctx, s.Repo, "DOMAIN_VERIFIED", map[string]interface{}{
 			"domain": req.Domain,
 			"domain_id": domain.ID.String(),
+			"method": verifiedBy,
 		})
 		
-		w.Write([]byte(`{"status": "verified"}`))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "verified", "method": verifiedBy})
 	} else {
 		// Audit log: Domain verification failed
 		auth.LogAction(ctx, s.Repo, "DOMAIN_VERIFY_FAILED", map[string]interface{}{
 			"domain": req.Domain,
 			"domain_id": domain.ID.String(),
+			"methods": methods,
 		})
 		
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusPreconditionFailed)
-		w.Write([]byte(fmt.Sprintf(`{"status": "failed", "expected": "cortex-verification=%s"}`, domain.VerificationToken)))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":   "failed",
+			"expected": "cortex-verification=" + domain.VerificationToken,
+			"options":  verificationOptions(req.Domain, domain.VerificationToken),
+		})
+	}
+}
+
+// verificationOptions describes what each ownership verification method expects to find
+func verificationOptions(domainName, token string) map[string]string {
+	return map[string]string{
+		discovery.MethodDNSTXT:       fmt.Sprintf("TXT record on %s: cortex-verification=%s", domainName, token),
+		discovery.MethodCNAME:        fmt.Sprintf("CNAME record _cortex.%s -> %s", domainName, discovery.VerificationCNAMETarget(token)),
+		discovery.MethodHTTPFile:     fmt.Sprintf("File at https://%s/.well-known/cortex-verification.txt containing %s", domainName, token),
+		discovery.MethodHTMLMeta:     fmt.Sprintf(`<meta name="cortex-verification" content="%s"> in the <head> of https://%s/`, token, domainName),
+		discovery.MethodParentDomain: "Verify a parent domain in the same organization; its subdomains are covered",
+	}
+}
+
+// hasVerifiedParent reports whether the org has verified a parent of domainName, which delegates ownership to it
+func (s *Server) hasVerifiedParent(ctx context.Context, orgID, domainName string) bool {
+	domains, err := s.Repo.GetVerifiedDomains(ctx, orgID)
+	if err != nil {
+		return false
+	}
+	for _, d := range domains {
+		if strings.HasSuffix(domainName, "."+d.RootDomain) {
+			return true
+		}
 	}
+	return false
 }
 
 func (s *Server) handleGetAssets(w http.ResponseWriter, r *http.Request) {
@@ -189,6 +260,66 @@ func (s *Server) handleGetAssets(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(assets)
 }
 
+func (s *Server) handleGetHosts(w http.ResponseWriter, r *http.Request) {
+	domainName := r.URL.Query().Get("domain")
+	if domainName == "" {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, "Domain query parameter required")
+		return
+	}
+
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	domain, err := s.Repo.GetDomainByNameAndOrg(ctx, domainName, orgID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusNotFound, errors.ErrCodeNotFound, "Domain not found")
+		return
+	}
+
+	hosts, err := s.Repo.GetHostsByDomain(ctx, domain.ID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to fetch hosts")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hosts)
+}
+
+func (s *Server) handleGetCertificates(w http.ResponseWriter, r *http.Request) {
+	domainName := r.URL.Query().Get("domain")
+	if domainName == "" {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, "Domain query parameter required")
+		return
+	}
+
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	domain, err := s.Repo.GetDomainByNameAndOrg(ctx, domainName, orgID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusNotFound, errors.ErrCodeNotFound, "Domain not found")
+		return
+	}
+
+	certs, err := s.Repo.GetCertificatesByDomain(ctx, domain.ID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to fetch certificates")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(certs)
+}
+
 func (s *Server) handleGetFindings(w http.ResponseWriter, r *http.Request) {
 	domainName := r.URL.Query().Get("domain")
 	if domainName == "" {
@@ -527,7 +658,8 @@ func (s *Server) handleCreateDomain(w http.ResponseWriter, r *http.Request) {
 		"rootDomain":        domain.RootDomain,
 		"verified":          domain.Verified,
 		"verificationToken":  domain.VerificationToken,
-		"message":           "Domain created successfully. Please verify ownership via DNS TXT record.",
+		"verificationOptions": verificationOptions(domain.RootDomain, domain.VerificationToken),
+		"message":           "Domain created successfully. Please verify ownership via DNS TXT record or one of the alternative methods.",
 	})
 }
 
@@ -603,3 +735,798 @@ func (s *Server) handleGetAllDomains(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(domains)
 }
+
+type ScanProfileRequest struct {
+	Domain  string `json:"domain"`
+	Profile string `json:"profile"`
+}
+
+func (s *Server) handleGetScanProfiles(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	plan, _ := s.Repo.GetOrgPlan(ctx, orgID.String())
+
+	var profiles []map[string]interface{}
+	for _, p := range scanning.Profiles() {
+		profiles = append(profiles, map[string]interface{}{
+			"name":        p.Name,
+			"description": p.Description,
+			"minPlan":     p.MinPlan,
+			"portCount":   len(p.PortList()),
+			"available":   p.AllowedFor(plan),
+			"default":     p.Name == scanning.DefaultProfile,
+		})
+	}
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i]["portCount"].(int) < profiles[j]["portCount"].(int)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profiles)
+}
+
+func (s *Server) handleSetScanProfile(w http.ResponseWriter, r *http.Request) {
+	var req ScanProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	if err := validation.ValidateDomain(req.Domain); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, err.Error())
+		return
+	}
+
+	profile := scanning.GetProfile(req.Profile)
+	if profile == nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, "Unknown scan profile")
+		return
+	}
+
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	plan, _ := s.Repo.GetOrgPlan(ctx, orgID.String())
+	if !profile.AllowedFor(plan) {
+		errors.WriteError(w, http.StatusForbidden, errors.ErrCodeForbidden, fmt.Sprintf("The %s scan profile requires the %s plan or higher", profile.Name, profile.MinPlan))
+		return
+	}
+
+	domain, err := s.Repo.GetDomainByNameAndOrg(ctx, req.Domain, orgID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusNotFound, errors.ErrCodeNotFound, "Domain not found")
+		return
+	}
+
+	if err := s.Repo.UpdateDomainScanProfile(ctx, domain.ID.String(), profile.Name); err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to update scan profile")
+		return
+	}
+
+	ctx = auth.WithRequest(ctx, r)
+	auth.LogAction(ctx, s.Repo, "SCAN_PROFILE_UPDATED", map[string]interface{}{
+		"domain":    req.Domain,
+		"domain_id": domain.ID.String(),
+		"profile":   profile.Name,
+	})
+
+	w.Write([]byte(`{"status": "success"}`))
+}
+
+type ScopeRuleRequest struct {
+	Domain   string `json:"domain"`
+	RuleType string `json:"ruleType"`
+	Pattern  string `json:"pattern"`
+}
+
+func (s *Server) handleAddScopeRule(w http.ResponseWriter, r *http.Request) {
+	var req ScopeRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	if err := validation.ValidateDomain(req.Domain); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, err.Error())
+		return
+	}
+	req.Pattern = strings.TrimSpace(req.Pattern)
+	if err := scope.ValidateRule(req.RuleType, req.Pattern); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	domain, err := s.Repo.GetDomainByNameAndOrg(ctx, req.Domain, orgID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusNotFound, errors.ErrCodeNotFound, "Domain not found")
+		return
+	}
+
+	rule, err := s.Repo.AddScopeRule(ctx, domain.ID.String(), req.RuleType, req.Pattern)
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to save scope rule")
+		return
+	}
+
+	ctx = auth.WithRequest(ctx, r)
+	auth.LogAction(ctx, s.Repo, "SCOPE_RULE_ADDED", map[string]interface{}{
+		"domain":    req.Domain,
+		"domain_id": domain.ID.String(),
+		"rule_type": rule.RuleType,
+		"pattern":   rule.Pattern,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+func (s *Server) handleGetScopeRules(w http.ResponseWriter, r *http.Request) {
+	domainName := r.URL.Query().Get("domain")
+	if domainName == "" {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, "Domain query parameter required")
+		return
+	}
+
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	domain, err := s.Repo.GetDomainByNameAndOrg(ctx, domainName, orgID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusNotFound, errors.ErrCodeNotFound, "Domain not found")
+		return
+	}
+
+	rules, err := s.Repo.GetScopeRules(ctx, domain.ID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to fetch scope rules")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+func (s *Server) handleDeleteScopeRule(w http.ResponseWriter, r *http.Request) {
+	domainName := r.URL.Query().Get("domain")
+	ruleID := r.URL.Query().Get("id")
+	if domainName == "" || ruleID == "" {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, "Domain and id query parameters required")
+		return
+	}
+	if _, err := uuid.Parse(ruleID); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, "Invalid rule id")
+		return
+	}
+
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	domain, err := s.Repo.GetDomainByNameAndOrg(ctx, domainName, orgID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusNotFound, errors.ErrCodeNotFound, "Domain not found")
+		return
+	}
+
+	deleted, err := s.Repo.DeleteScopeRule(ctx, domain.ID.String(), ruleID)
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to delete scope rule")
+		return
+	}
+	if !deleted {
+		errors.WriteError(w, http.StatusNotFound, errors.ErrCodeNotFound, "Scope rule not found")
+		return
+	}
+
+	ctx = auth.WithRequest(ctx, r)
+	auth.LogAction(ctx, s.Repo, "SCOPE_RULE_REMOVED", map[string]interface{}{
+		"domain":    domainName,
+		"domain_id": domain.ID.String(),
+		"rule_id":   ruleID,
+	})
+
+	w.Write([]byte(`{"status": "deleted"}`))
+}
+
+type CreateRangeRequest struct {
+	CIDR string `json:"cidr"`
+	ASN  string `json:"asn"`
+}
+
+type RangeRequest struct {
+	CIDR string `json:"cidr"`
+}
+
+// rangeResponse describes a registered range and where its verification records must be published
+func rangeResponse(ir *models.IPRange) map[string]interface{} {
+	recordNames, _ := discovery.RangeVerificationNames(ir.CIDR)
+	return map[string]interface{}{
+		"id":                  ir.ID.String(),
+		"cidr":                ir.CIDR,
+		"asn":                 ir.ASN,
+		"verified":            ir.Verified,
+		"verificationToken":   ir.VerificationToken,
+		"verificationRecords": recordNames,
+		"verificationValue":   "cortex-verification=" + ir.VerificationToken,
+	}
+}
+
+func (s *Server) handleCreateRange(w http.ResponseWriter, r *http.Request) {
+	var req CreateRangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	// An ASN is seeded by registering each prefix it announces; every prefix is verified on its own
+	var cidrs []string
+	var skipped []string
+	if req.ASN != "" {
+		if err := validation.ValidateASN(req.ASN); err != nil {
+			errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, err.Error())
+			return
+		}
+		prefixes, err := discovery.ASNPrefixes(ctx, req.ASN)
+		if err != nil {
+			errors.WriteError(w, http.StatusBadGateway, errors.ErrCodeInternalError, "Failed to look up announced prefixes for ASN")
+			return
+		}
+		for _, prefix := range prefixes {
+			if validation.ValidateCIDR(prefix) != nil {
+				skipped = append(skipped, prefix)
+				continue
+			}
+			if claimed, err := s.Repo.IPRangeClaimedByOtherOrg(ctx, prefix, orgID.String()); err != nil || claimed {
+				skipped = append(skipped, prefix)
+				continue
+			}
+			cidrs = append(cidrs, prefix)
+		}
+	} else {
+		if err := validation.ValidateCIDR(req.CIDR); err != nil {
+			errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, err.Error())
+			return
+		}
+		claimed, err := s.Repo.IPRangeClaimedByOtherOrg(ctx, req.CIDR, orgID.String())
+		if err != nil {
+			errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to check existing IP ranges")
+			return
+		}
+		if claimed {
+			errors.WriteError(w, http.StatusConflict, "RANGE_CLAIMED", "This range overlaps a range verified by another organization")
+			return
+		}
+		cidrs = append(cidrs, req.CIDR)
+	}
+
+	var ranges []map[string]interface{}
+	ctx = auth.WithRequest(ctx, r)
+	for _, cidr := range cidrs {
+		ir, err := s.Repo.CreateIPRange(ctx, orgID.String(), cidr, strings.ToUpper(req.ASN))
+		if err != nil {
+			errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to create IP range")
+			return
+		}
+
+		auth.LogAction(ctx, s.Repo, "RANGE_CREATED", map[string]interface{}{
+			"cidr":     ir.CIDR,
+			"asn":      ir.ASN,
+			"range_id": ir.ID.String(),
+		})
+		ranges = append(ranges, rangeResponse(ir))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ranges":  ranges,
+		"skipped": skipped, // Prefixes too large, not public or verified by another organization
+		"message": "Publish each verificationValue as a TXT record at every one of its verificationRecords in the range's reverse DNS zones, then verify.",
+	})
+}
+
+func (s *Server) handleVerifyRange(w http.ResponseWriter, r *http.Request) {
+	var req RangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	if err := validation.ValidateCIDR(req.CIDR); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	ipRange, err := s.Repo.GetIPRangeByCIDRAndOrg(ctx, req.CIDR, orgID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusNotFound, errors.ErrCodeNotFound, "IP range not found")
+		return
+	}
+
+	// A range verified by another org in the meantime cannot be claimed twice
+	claimed, err := s.Repo.IPRangeClaimedByOtherOrg(ctx, ipRange.CIDR, orgID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to check existing IP ranges")
+		return
+	}
+	if claimed {
+		errors.WriteError(w, http.StatusConflict, "RANGE_CLAIMED", "This range overlaps a range verified by another organization")
+		return
+	}
+
+	missing, err := discovery.VerifyIPRange(ipRange.CIDR, ipRange.VerificationToken)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Verification check failed: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	ctx = auth.WithRequest(ctx, r)
+
+	if len(missing) == 0 {
+		s.Repo.UpdateIPRangeVerification(ctx, ipRange.ID.String(), true)
+
+		auth.LogAction(ctx, s.Repo, "RANGE_VERIFIED", map[string]interface{}{
+			"cidr":     ipRange.CIDR,
+			"range_id": ipRange.ID.String(),
+		})
+
+		w.Write([]byte(`{"status": "verified"}`))
+	} else {
+		auth.LogAction(ctx, s.Repo, "RANGE_VERIFY_FAILED", map[string]interface{}{
+			"cidr":     ipRange.CIDR,
+			"range_id": ipRange.ID.String(),
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusPreconditionFailed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":   "failed",
+			"missing":  missing, // Records that do not hold the token yet
+			"expected": "cortex-verification=" + ipRange.VerificationToken,
+		})
+	}
+}
+
+func (s *Server) handleGetRanges(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	ranges, err := s.Repo.GetIPRangesByOrg(ctx, orgID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to fetch IP ranges")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ranges)
+}
+
+func (s *Server) handleScanRange(w http.ResponseWriter, r *http.Request) {
+	var req RangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	if err := validation.ValidateCIDR(req.CIDR); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	ipRange, err := s.Repo.GetIPRangeByCIDRAndOrg(ctx, req.CIDR, orgID.String())
+	if err != nil || !ipRange.Verified {
+		errors.WriteError(w, http.StatusForbidden, errors.ErrCodeDomainNotVerified, "IP range not verified. Please verify ownership via a reverse DNS TXT record first.")
+		return
+	}
+
+	ctx = auth.WithRequest(ctx, r)
+	jobID := s.Queue.EnqueueRange(ipRange.CIDR, ipRange.ID.String())
+
+	auth.LogAction(ctx, s.Repo, "SCAN_QUEUED", map[string]interface{}{
+		"cidr":     ipRange.CIDR,
+		"range_id": ipRange.ID.String(),
+		"job_id":   jobID,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"jobId":   jobID,
+		"status":  "queued",
+		"message": "Range scan has been queued and will be processed shortly",
+	})
+}
+
+type RangeScanProfileRequest struct {
+	CIDR    string `json:"cidr"`
+	Profile string `json:"profile"`
+}
+
+func (s *Server) handleSetRangeScanProfile(w http.ResponseWriter, r *http.Request) {
+	var req RangeScanProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	if err := validation.ValidateCIDR(req.CIDR); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, err.Error())
+		return
+	}
+
+	profile := scanning.GetProfile(req.Profile)
+	if profile == nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, "Unknown scan profile")
+		return
+	}
+
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	plan, _ := s.Repo.GetOrgPlan(ctx, orgID.String())
+	if !profile.AllowedFor(plan) {
+		errors.WriteError(w, http.StatusForbidden, errors.ErrCodeForbidden, fmt.Sprintf("The %s scan profile requires the %s plan or higher", profile.Name, profile.MinPlan))
+		return
+	}
+
+	ipRange, err := s.Repo.GetIPRangeByCIDRAndOrg(ctx, req.CIDR, orgID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusNotFound, errors.ErrCodeNotFound, "IP range not found")
+		return
+	}
+
+	if err := s.Repo.UpdateIPRangeScanProfile(ctx, ipRange.ID.String(), profile.Name); err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to update scan profile")
+		return
+	}
+
+	ctx = auth.WithRequest(ctx, r)
+	auth.LogAction(ctx, s.Repo, "SCAN_PROFILE_UPDATED", map[string]interface{}{
+		"cidr":     ipRange.CIDR,
+		"range_id": ipRange.ID.String(),
+		"profile":  profile.Name,
+	})
+
+	w.Write([]byte(`{"status": "success"}`))
+}
+
+// rangeFromQuery resolves the ?cidr= parameter to a range owned by the caller's org, writing an error if it fails
+func (s *Server) rangeFromQuery(w http.ResponseWriter, r *http.Request) *models.IPRange {
+	cidr := r.URL.Query().Get("cidr")
+	if err := validation.ValidateCIDR(cidr); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, err.Error())
+		return nil
+	}
+
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return nil
+	}
+
+	ipRange, err := s.Repo.GetIPRangeByCIDRAndOrg(ctx, cidr, orgID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusNotFound, errors.ErrCodeNotFound, "IP range not found")
+		return nil
+	}
+	return ipRange
+}
+
+func (s *Server) handleGetRangeAssets(w http.ResponseWriter, r *http.Request) {
+	ipRange := s.rangeFromQuery(w, r)
+	if ipRange == nil {
+		return
+	}
+
+	assets, err := s.Repo.GetAssetsByRange(r.Context(), ipRange.ID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to fetch assets")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assets)
+}
+
+func (s *Server) handleGetRangeFindings(w http.ResponseWriter, r *http.Request) {
+	ipRange := s.rangeFromQuery(w, r)
+	if ipRange == nil {
+		return
+	}
+
+	findings, err := s.Repo.GetLatestFindingsForRange(r.Context(), ipRange.ID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to fetch findings")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(findings)
+}
+
+// handleGetScannerAddresses lists the source addresses scans come from so customers can allowlist them
+func (s *Server) handleGetScannerAddresses(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"addresses": outbound.PublishedAddresses(),
+	})
+}
+
+type CreateAgentRequest struct {
+	Name string `json:"name"`
+}
+
+// handleCreateAgent registers a scan agent and returns its token; only a hash is kept, so it is shown once
+func (s *Server) handleCreateAgent(w http.ResponseWriter, r *http.Request) {
+	var req CreateAgentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	if err := validation.ValidateAgentName(req.Name); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, err.Error())
+		return
+	}
+
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	token, err := auth.GenerateAgentToken()
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to generate agent token")
+		return
+	}
+
+	agent, err := s.Repo.CreateAgent(ctx, orgID.String(), req.Name, auth.HashAgentToken(token))
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to create agent")
+		return
+	}
+
+	ctx = auth.WithRequest(ctx, r)
+	auth.LogAction(ctx, s.Repo, "AGENT_CREATE", map[string]interface{}{
+		"agentId": agent.ID.String(),
+		"name":    agent.Name,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"agent": agent,
+		"token": token,
+	})
+}
+
+func (s *Server) handleGetAgents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	agents, err := s.Repo.GetAgentsByOrg(ctx, orgID.String())
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to fetch agents")
+		return
+	}
+	if agents == nil {
+		agents = []models.Agent{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(agents)
+}
+
+// handleDeleteAgent removes an agent, revoking its token
+func (s *Server) handleDeleteAgent(w http.ResponseWriter, r *http.Request) {
+	agentID := r.URL.Query().Get("id")
+	if _, err := uuid.Parse(agentID); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, "Valid id query parameter required")
+		return
+	}
+
+	ctx := r.Context()
+	orgID, ok := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	deleted, err := s.Repo.DeleteAgent(ctx, orgID.String(), agentID)
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to delete agent")
+		return
+	}
+	if !deleted {
+		errors.WriteError(w, http.StatusNotFound, errors.ErrCodeNotFound, "Agent not found")
+		return
+	}
+
+	ctx = auth.WithRequest(ctx, r)
+	auth.LogAction(ctx, s.Repo, "AGENT_DELETE", map[string]interface{}{
+		"agentId": agentID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// agentPollInterval tells agents how often to ask for work
+const agentPollInterval = 5 * time.Second
+
+// maxAgentResultBytes bounds a task result an agent may upload
+const maxAgentResultBytes = 16 << 20
+
+type AgentRegisterRequest struct {
+	Hostname string `json:"hostname"`
+	Version  string `json:"version"`
+}
+
+// handleAgentRegister records an agent coming online and tells it how to poll
+func (s *Server) handleAgentRegister(w http.ResponseWriter, r *http.Request) {
+	var req AgentRegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+
+	ctx := r.Context()
+	agentID, ok := ctx.Value(auth.AgentIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+	orgID := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+
+	hostname := validation.SanitizeString(req.Hostname)
+	version := validation.SanitizeString(req.Version)
+	if len(hostname) > 253 || len(version) > 64 {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, "Hostname or version too long")
+		return
+	}
+
+	address := auth.GetClientIP(r)
+	if err := s.Repo.RecordAgentCheckIn(ctx, agentID, hostname, version, address); err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to register agent")
+		return
+	}
+
+	ctx = auth.WithRequest(ctx, r)
+	auth.LogAction(ctx, s.Repo, "AGENT_REGISTER", map[string]interface{}{
+		"agentId":  agentID.String(),
+		"hostname": hostname,
+		"version":  version,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"agentId":             agentID,
+		"orgId":               orgID,
+		"pollIntervalSeconds": int(agentPollInterval / time.Second),
+	})
+}
+
+// handleAgentNextTask hands the agent the oldest pending task of its org, or 204 if there is none
+func (s *Server) handleAgentNextTask(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	agentID, ok := ctx.Value(auth.AgentIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+	orgID := ctx.Value(auth.OrgIDKey).(uuid.UUID)
+
+	s.Repo.TouchAgent(ctx, agentID)
+	task, err := s.Repo.ClaimAgentTask(ctx, orgID, agentID)
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to fetch task")
+		return
+	}
+	if task == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tasks.Task{ID: task.ID, Kind: task.Kind, Payload: task.Payload})
+}
+
+type AgentTaskResultRequest struct {
+	TaskID uuid.UUID       `json:"taskId"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// handleAgentTaskResult stores what an agent observed; the waiting orchestrator picks it up
+func (s *Server) handleAgentTaskResult(w http.ResponseWriter, r *http.Request) {
+	var req AgentTaskResultRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAgentResultBytes)).Decode(&req); err != nil {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.Error == "" && len(req.Result) == 0 {
+		errors.WriteError(w, http.StatusBadRequest, errors.ErrCodeValidationError, "Either result or error is required")
+		return
+	}
+
+	ctx := r.Context()
+	agentID, ok := ctx.Value(auth.AgentIDKey).(uuid.UUID)
+	if !ok {
+		errors.WriteError(w, http.StatusUnauthorized, errors.ErrCodeUnauthorized, "Unauthorized")
+		return
+	}
+
+	taskErr := validation.SanitizeString(req.Error)
+	if len(taskErr) > 1000 {
+		taskErr = taskErr[:1000]
+	}
+	completed, err := s.Repo.CompleteAgentTask(ctx, req.TaskID, agentID, req.Result, taskErr)
+	if err != nil {
+		errors.WriteError(w, http.StatusInternalServerError, errors.ErrCodeInternalError, "Failed to store task result")
+		return
+	}
+	if !completed {
+		errors.WriteError(w, http.StatusNotFound, errors.ErrCodeNotFound, "Task not found or no longer assigned to this agent")
+		return
+	}
+	// An agent busy with long tasks reports results without polling in between
+	s.Repo.TouchAgent(ctx, agentID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
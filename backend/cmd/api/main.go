@@ -17,6 +17,7 @@ import (
 	"cortex-backend/internal/auth"
 	"cortex-backend/internal/ratelimit"
 	httpsmiddleware "cortex-backend/internal/middleware"
+	"cortex-backend/internal/outbound"
 	"cortex-backend/internal/persistence"
 	"cortex-backend/internal/queue"
 	"cortex-backend/internal/scanner"
@@ -33,6 +34,11 @@ func main() {
 		log.Fatalf("JWT Secret Key validation failed: %v", err)
 	}
 
+	// Validate the outbound proxy / egress pool before any scan dials out
+	if _, err := outbound.DialerFromEnv(); err != nil {
+		log.Fatalf("Outbound network configuration invalid: %v", err)
+	}
+
 	// Initialize Database
 	database, err := db.Connect()
 	if err != nil {
@@ -112,6 +118,14 @@ func main() {
 		r.With(authLimiter.Limit).Post("/auth/register", srv.handleRegister)
 		r.With(authLimiter.Limit).Post("/auth/login", srv.handleLogin)
 
+		// Scan agent routes, authenticated with an agent token instead of a user session
+		r.Route("/agent", func(r chi.Router) {
+			r.Use(auth.AgentMiddleware(repo))
+			r.Post("/register", srv.handleAgentRegister)
+			r.Post("/tasks/next", srv.handleAgentNextTask)
+			r.Post("/tasks/result", srv.handleAgentTaskResult)
+		})
+
 		// Protected Routes
 		r.Group(func(r chi.Router) {
 			r.Use(auth.AuthMiddleware)
@@ -121,14 +135,34 @@ func main() {
 			r.With(scanLimiter.Limit).Post("/scan", srv.handleScan)
 			r.Post("/domains", srv.handleCreateDomain)
 			r.Post("/domains/verify", srv.handleVerify)
+			r.Post("/domains/scope", srv.handleAddScopeRule)
+			r.Get("/domains/scope", srv.handleGetScopeRules)
+			r.Delete("/domains/scope", srv.handleDeleteScopeRule)
+			r.Put("/domains/scan-profile", srv.handleSetScanProfile)
+			r.Get("/scan-profiles", srv.handleGetScanProfiles)
+			r.Get("/scanner/addresses", srv.handleGetScannerAddresses)
+			r.Post("/agents", srv.handleCreateAgent)
+			r.Get("/agents", srv.handleGetAgents)
+			r.Delete("/agents", srv.handleDeleteAgent)
 			r.Get("/stats", srv.handleStats)
 			r.Get("/assets", srv.handleGetAssets)
+			r.Get("/hosts", srv.handleGetHosts)
 			r.Get("/services", srv.handleGetServices)
+			r.Get("/certificates", srv.handleGetCertificates)
 			r.Get("/findings", srv.handleGetFindings)
 			r.Get("/domains", srv.handleGetDomains)
 			r.Get("/domains/all", srv.handleGetAllDomains)
 			r.Get("/scans/status", srv.handleGetScanStatus)
 
+			// IP Range Routes
+			r.Post("/ranges", srv.handleCreateRange)
+			r.Post("/ranges/verify", srv.handleVerifyRange)
+			r.With(scanLimiter.Limit).Post("/ranges/scan", srv.handleScanRange)
+			r.Get("/ranges", srv.handleGetRanges)
+			r.Get("/ranges/assets", srv.handleGetRangeAssets)
+			r.Get("/ranges/findings", srv.handleGetRangeFindings)
+			r.Put("/ranges/scan-profile", srv.handleSetRangeScanProfile)
+
 			// Billing Routes
 			r.Get("/billing/plan", srv.handleGetPlan)
 			r.Put("/billing/plan", srv.handleUpdatePlan)
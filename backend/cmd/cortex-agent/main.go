@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"cortex-backend/internal/agent"
+	"cortex-backend/internal/outbound"
+	"github.com/joho/godotenv"
+)
+
+// cortex-agent runs scan tasks for one organization from the machine it is installed on.
+//
+//	CORTEX_API_URL            API base URL (default http://localhost:8080/api/v1)
+//	CORTEX_AGENT_TOKEN        token returned when the agent was created (required)
+//	CORTEX_AGENT_CONCURRENCY  tasks run at once (default 4)
+//
+// OUTBOUND_* settings (proxy, source addresses, politeness limits) apply as on the API server.
+func main() {
+	godotenv.Load()
+
+	token := os.Getenv("CORTEX_AGENT_TOKEN")
+	if token == "" {
+		log.Fatal("CORTEX_AGENT_TOKEN is required")
+	}
+	apiURL := os.Getenv("CORTEX_API_URL")
+	if apiURL == "" {
+		apiURL = "http://localhost:8080/api/v1"
+	}
+	concurrency := 4
+	if v, err := strconv.Atoi(os.Getenv("CORTEX_AGENT_CONCURRENCY")); err == nil && v > 0 {
+		concurrency = v
+	}
+
+	if _, err := outbound.DialerFromEnv(); err != nil {
+		log.Fatalf("Outbound network configuration invalid: %v", err)
+	}
+
+	hostname, _ := os.Hostname()
+	a := &agent.Agent{
+		Client:      agent.NewClient(apiURL, token),
+		Hostname:    hostname,
+		Concurrency: concurrency,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Starting cortex-agent %s against %s...", agent.Version, apiURL)
+	if err := a.Run(ctx); err != nil {
+		log.Fatalf("Agent stopped: %v", err)
+	}
+	log.Println("Agent stopped")
+}
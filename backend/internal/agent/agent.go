@@ -0,0 +1,86 @@
+// Package agent runs scan tasks handed out by the Cortex API from a remote vantage point
+package agent
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"cortex-backend/internal/tasks"
+)
+
+// Version is reported to the API on registration
+const Version = "1.0.0"
+
+// Agent polls the API for tasks and runs up to Concurrency of them at once
+type Agent struct {
+	Client      *Client
+	Hostname    string
+	Concurrency int
+}
+
+// Run registers the agent and works on tasks until ctx is cancelled
+func (a *Agent) Run(ctx context.Context) error {
+	reg, err := a.Client.Register(ctx, a.Hostname, Version)
+	if err != nil {
+		return err
+	}
+	interval := time.Duration(reg.PollIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	log.Printf("[Agent] Registered as %s for org %s, polling every %s", reg.AgentID, reg.OrgID, interval)
+
+	slots := make(chan struct{}, a.Concurrency)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		// Claim work only while a slot is free, so queued tasks stay available to other agents
+		select {
+		case <-ctx.Done():
+			return nil
+		case slots <- struct{}{}:
+		}
+
+		task, err := a.Client.NextTask(ctx)
+		if err != nil || task == nil {
+			<-slots
+			if err != nil && ctx.Err() == nil {
+				log.Printf("[Agent] Polling failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(interval):
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			a.execute(ctx, task)
+		}()
+	}
+}
+
+// execute runs one task and reports its outcome
+func (a *Agent) execute(ctx context.Context, task *tasks.Task) {
+	started := time.Now()
+	result, err := tasks.Execute(ctx, *task)
+	if err != nil {
+		log.Printf("[Agent] Task %s (%s) failed: %v", task.ID, task.Kind, err)
+	} else {
+		log.Printf("[Agent] Task %s (%s) finished in %s", task.ID, task.Kind, time.Since(started).Round(time.Millisecond))
+	}
+
+	// Report even if we are shutting down, so the orchestrator does not wait for the timeout
+	submitCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := a.Client.SubmitResult(submitCtx, task.ID, result, err); err != nil {
+		log.Printf("[Agent] Failed to submit result of task %s: %v", task.ID, err)
+	}
+}
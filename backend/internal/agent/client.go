@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"cortex-backend/internal/tasks"
+	"github.com/google/uuid"
+)
+
+// Client talks to the agent endpoints of the Cortex API
+type Client struct {
+	BaseURL string // e.g. http://localhost:8080/api/v1
+	Token   string
+	HTTP    *http.Client
+}
+
+// NewClient creates a client for the API at baseURL
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Registration is the API's answer to a register call
+type Registration struct {
+	AgentID             uuid.UUID `json:"agentId"`
+	OrgID               uuid.UUID `json:"orgId"`
+	PollIntervalSeconds int       `json:"pollIntervalSeconds"`
+}
+
+// Register announces the agent to the API
+func (c *Client) Register(ctx context.Context, hostname, version string) (*Registration, error) {
+	var reg Registration
+	status, err := c.post(ctx, "/agent/register", map[string]string{"hostname": hostname, "version": version}, &reg)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("registration failed with status %d", status)
+	}
+	return &reg, nil
+}
+
+// NextTask claims the next pending task, returning nil if there is none
+func (c *Client) NextTask(ctx context.Context) (*tasks.Task, error) {
+	var task tasks.Task
+	status, err := c.post(ctx, "/agent/tasks/next", struct{}{}, &task)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case http.StatusOK:
+		return &task, nil
+	case http.StatusNoContent:
+		return nil, nil
+	}
+	return nil, fmt.Errorf("polling for tasks failed with status %d", status)
+}
+
+// SubmitResult reports the result of a task, or the error that stopped it
+func (c *Client) SubmitResult(ctx context.Context, taskID uuid.UUID, result json.RawMessage, taskErr error) error {
+	body := map[string]interface{}{"taskId": taskID}
+	if taskErr != nil {
+		body["error"] = taskErr.Error()
+	} else {
+		body["result"] = result
+	}
+	status, err := c.post(ctx, "/agent/tasks/result", body, nil)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusNoContent {
+		return fmt.Errorf("submitting result of task %s failed with status %d", taskID, status)
+	}
+	return nil
+}
+
+// post sends a JSON request and decodes a JSON response into out when the API returns 200
+func (c *Client) post(ctx context.Context, path string, body interface{}, out interface{}) (int, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK && out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("invalid response from %s: %v", path, err)
+		}
+	} else {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	}
+	return resp.StatusCode, nil
+}
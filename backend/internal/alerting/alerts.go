@@ -88,6 +88,11 @@ func (a *AlertHandler) sendEmail(domain string, findings []risk.Exposure) error
 	subject := fmt.Sprintf("🚨 Security Alert: %d Critical Findings for %s", len(findings), domain)
 	body := a.formatEmailBody(domain, findings)
 
+	return a.sendMail(to, subject, body)
+}
+
+// sendMail delivers an HTML email through the configured SMTP server
+func (a *AlertHandler) sendMail(to, subject, body string) error {
 	// SMTP configuration
 	addr := fmt.Sprintf("%s:%s", a.SMTPHost, a.SMTPPort)
 	auth := smtp.PlainAuth("", a.SMTPUser, a.SMTPPassword, a.SMTPHost)
@@ -106,6 +111,47 @@ func (a *AlertHandler) sendEmail(domain string, findings []risk.Exposure) error
 	return nil
 }
 
+// SendVerificationWarning notifies that ownership of a domain could not be reconfirmed.
+// Before graceEnds it is a warning; once suspended is true, scheduled scans have stopped.
+func (a *AlertHandler) SendVerificationWarning(domain string, graceEnds time.Time, suspended bool) error {
+	subject := fmt.Sprintf("⚠️ Action required: ownership of %s could not be re-verified", domain)
+	message := fmt.Sprintf("We could not find your verification record for %s. Restore it before %s or scheduled scans will be suspended.",
+		domain, graceEnds.Format("2006-01-02 15:04 MST"))
+	if suspended {
+		subject = fmt.Sprintf("⛔ Scans suspended: ownership of %s could not be re-verified", domain)
+		message = fmt.Sprintf("Ownership of %s could not be confirmed within the grace period. Scheduled scans are suspended until the domain is verified again.", domain)
+	}
+	log.Printf("[Alert] %s", message)
+
+	if a.SMTPHost != "" && a.SMTPUser != "" {
+		to := os.Getenv("SMTP_TO")
+		if to == "" {
+			to = a.SMTPFrom
+		}
+		if err := a.sendMail(to, subject, fmt.Sprintf("<html><body><p>%s</p></body></html>", message)); err != nil {
+			log.Printf("Failed to send verification email: %v", err)
+		}
+	}
+
+	if a.WebhookURL != "" {
+		event := "verification_warning"
+		if suspended {
+			event = "scans_suspended"
+		}
+		if err := a.postWebhook(map[string]interface{}{
+			"event":     event,
+			"domain":    domain,
+			"timestamp": time.Now().Format(time.RFC3339),
+			"graceEnds": graceEnds.Format(time.RFC3339),
+			"message":   message,
+		}); err != nil {
+			log.Printf("Failed to send verification webhook: %v", err)
+		}
+	}
+
+	return nil
+}
+
 // sendWebhook sends a webhook alert
 func (a *AlertHandler) sendWebhook(domain string, findings []risk.Exposure) error {
 	payload := map[string]interface{}{
@@ -115,6 +161,11 @@ func (a *AlertHandler) sendWebhook(domain string, findings []risk.Exposure) erro
 		"findings":  findings,
 	}
 
+	return a.postWebhook(payload)
+}
+
+// postWebhook POSTs a JSON payload to the configured webhook
+func (a *AlertHandler) postWebhook(payload map[string]interface{}) error {
 	jsonData, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("failed to marshal webhook payload: %w", err)
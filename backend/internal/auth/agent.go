@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"cortex-backend/internal/persistence"
+)
+
+// AgentIDKey holds the authenticated agent's ID on agent API requests
+const AgentIDKey contextKey = "agentID"
+
+// agentTokenPrefix makes agent tokens recognizable in logs and secret scanners
+const agentTokenPrefix = "cta_"
+
+// GenerateAgentToken returns a new random agent token
+func GenerateAgentToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return agentTokenPrefix + hex.EncodeToString(b), nil
+}
+
+// HashAgentToken returns the form of an agent token stored in the database
+func HashAgentToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// AgentMiddleware authenticates scan agents by their bearer token and adds the agent and its org to the context
+func AgentMiddleware(repo *persistence.Repository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || !strings.HasPrefix(token, agentTokenPrefix) {
+				http.Error(w, "agent token required", http.StatusUnauthorized)
+				return
+			}
+
+			agent, err := repo.GetAgentByTokenHash(r.Context(), HashAgentToken(token))
+			if err != nil {
+				http.Error(w, "invalid agent token", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), AgentIDKey, agent.ID)
+			ctx = context.WithValue(ctx, OrgIDKey, agent.OrgID)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package container
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"cortex-backend/internal/fingerprinting"
+	"cortex-backend/internal/outbound"
+)
+
+// probeMaxBody bounds what active probes read from a response
+const probeMaxBody = 512 * 1024
+
+// IngressInfo describes the ingress controller or API gateway answering on a port
+type IngressInfo struct {
+	Controller Technology // "ingress-nginx", "traefik", "istio", "envoy", "haproxy", "contour", "kong"
+	Name       string
+	Version    string
+	Issues     []Issue
+}
+
+// Issue is a risky configuration found by an active probe
+type Issue struct {
+	Severity    string // "critical", "high", "medium", "low"
+	Title       string
+	Description string
+	Remediation string
+	Evidence    []string // What the probe saw, e.g. repository names
+}
+
+// Well-known ports of management interfaces that are only checked where they are expected
+var (
+	traefikAPIPorts   = []int{8080, 9000}
+	envoyAdminPorts   = []int{9901, 15000}
+	istiodDebugPorts  = []int{15014}
+	nginxMetricsPorts = []int{10254}
+	haproxyStatsPorts = []int{1936, 8404}
+	contourDebugPorts = []int{6060, 8000}
+	kongAdminPorts    = []int{8001, 8444}
+
+	nginxReleaseRegex   = regexp.MustCompile(`nginx_ingress_controller_build_info\{[^}]*release="v?([\w.\-]+)"`)
+	contourVersionRegex = regexp.MustCompile(`contour_build_info\{[^}]*version="v?([\w.\-]+)"`)
+	haproxyVersionRegex = regexp.MustCompile(`HAProxy version ([\d.]+)`)
+)
+
+// ProbeIngress identifies ingress controllers and API gateways on an HTTP port from the technologies
+// already matched and from read-only requests to their version, status and admin endpoints.
+// baseURL is the scheme and address the fingerprint was taken from. Returns nil if none was found.
+func ProbeIngress(ctx context.Context, baseURL string, port int, matches []Match, fp *fingerprinting.Fingerprint) *IngressInfo {
+	if fp == nil {
+		return nil
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+	client := &http.Client{
+		Transport: outbound.Transport(&tls.Config{InsecureSkipVerify: true}),
+		Timeout:   5 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	var info *IngressInfo
+	for _, m := range matches {
+		if m.Category == "ingress" {
+			info = &IngressInfo{Controller: m.Technology, Name: m.Name, Version: m.Version}
+			break
+		}
+	}
+	if info != nil {
+		// ingress-nginx serves stock NGINX error pages, which leak the NGINX rather than the controller version
+		product, version := info.Name, info.Version
+		if info.Controller == "ingress-nginx" {
+			for _, m := range matches {
+				if m.Technology == "nginx" {
+					product, version = "NGINX", m.Version
+				}
+			}
+		}
+		if version != "" {
+			info.Issues = append(info.Issues, Issue{
+				Severity:    "low",
+				Title:       "Ingress Controller Discloses Its Version",
+				Description: fmt.Sprintf("The %s on port %d reveals %s %s in its headers or default error pages, letting attackers match it against known vulnerabilities.", info.Name, port, product, version),
+				Remediation: versionRemediation(info.Controller),
+			})
+		}
+	}
+
+	if (info != nil && info.Controller == "traefik") || hasPort(traefikAPIPorts, port) {
+		info = checkTraefikAPI(ctx, client, baseURL, port, info)
+	}
+	if hasPort(envoyAdminPorts, port) || fp.Title == "Envoy Admin" {
+		info = checkEnvoyAdmin(ctx, client, baseURL, port, info)
+	}
+	if hasPort(istiodDebugPorts, port) {
+		info = checkIstiodDebug(ctx, client, baseURL, port, info)
+	}
+	if hasPort(nginxMetricsPorts, port) {
+		info = checkNginxMetrics(ctx, client, baseURL, port, info)
+	}
+	if (info != nil && info.Controller == "haproxy") || hasPort(haproxyStatsPorts, port) {
+		info = checkHAProxyStats(ctx, client, baseURL, port, info)
+	}
+	if hasPort(contourDebugPorts, port) {
+		info = checkContourDebug(ctx, client, baseURL, port, info)
+	}
+	if hasPort(kongAdminPorts, port) {
+		info = checkKongAdmin(ctx, client, baseURL, port, info)
+	}
+	return info
+}
+
+// checkTraefikAPI looks for the Traefik API, which serves the dashboard and the full routing configuration
+func checkTraefikAPI(ctx context.Context, client *http.Client, baseURL string, port int, info *IngressInfo) *IngressInfo {
+	status, body := fetch(ctx, client, baseURL+"/api/version")
+	var version struct {
+		Version  string `json:"Version"`
+		Codename string `json:"Codename"`
+	}
+	if status != http.StatusOK || json.Unmarshal(body, &version) != nil || version.Version == "" || version.Codename == "" {
+		return info
+	}
+	info = identified(info, "traefik", "Traefik", version.Version)
+	info.Issues = append(info.Issues, Issue{
+		Severity:    "high",
+		Title:       "Exposed Traefik Dashboard and API",
+		Description: fmt.Sprintf("The Traefik API and dashboard (version %s) are reachable without authentication on port %d. They list every router, service, middleware and backend address of the cluster.", version.Version, port),
+		Remediation: "Disable api.insecure and expose the dashboard only through a router protected by an authentication middleware on an internal entrypoint.",
+	})
+	return info
+}
+
+// checkEnvoyAdmin looks for the Envoy admin interface, used directly or as the Istio sidecar and gateway admin port
+func checkEnvoyAdmin(ctx context.Context, client *http.Client, baseURL string, port int, info *IngressInfo) *IngressInfo {
+	status, body := fetch(ctx, client, baseURL+"/server_info")
+	var serverInfo struct {
+		Version            string                 `json:"version"`
+		State              string                 `json:"state"`
+		CommandLineOptions map[string]interface{} `json:"command_line_options"`
+	}
+	if status != http.StatusOK || json.Unmarshal(body, &serverInfo) != nil || serverInfo.Version == "" || serverInfo.State == "" {
+		return info
+	}
+	// "<commit>/1.28.0/Clean/RELEASE/BoringSSL"
+	version := ""
+	if parts := strings.Split(serverInfo.Version, "/"); len(parts) > 1 {
+		version = parts[1]
+	}
+
+	name, controller := "Envoy", Technology("envoy")
+	if cluster, _ := serverInfo.CommandLineOptions["service_cluster"].(string); strings.Contains(cluster, "istio") || port == 15000 {
+		name, controller = "Istio", Technology("istio")
+	}
+	info = identified(info, controller, name, version)
+	info.Issues = append(info.Issues, Issue{
+		Severity:    "critical",
+		Title:       "Exposed Envoy Admin Interface",
+		Description: fmt.Sprintf("The Envoy admin interface of %s is reachable on port %d. /config_dump reveals listeners, routes, clusters and secrets, and its POST endpoints can change log levels, reset counters or shut the proxy down.", name, port),
+		Remediation: "Bind the Envoy admin interface to 127.0.0.1 (admin.address) and never publish port 15000 or 9901 through a Service or load balancer.",
+	})
+	return info
+}
+
+// checkIstiodDebug looks for the istiod debug endpoints, which dump the service registry and proxy configuration
+func checkIstiodDebug(ctx context.Context, client *http.Client, baseURL string, port int, info *IngressInfo) *IngressInfo {
+	status, body := fetch(ctx, client, baseURL+"/debug/registryz")
+	var registry []json.RawMessage
+	if status != http.StatusOK || json.Unmarshal(body, &registry) != nil {
+		return info
+	}
+	info = identified(info, "istio", "Istio", "")
+	info.Issues = append(info.Issues, Issue{
+		Severity:    "high",
+		Title:       "Exposed Istiod Debug Interface",
+		Description: fmt.Sprintf("The istiod debug endpoints are reachable without authentication on port %d. They list every service in the mesh (%d registered) and the configuration pushed to each proxy.", port, len(registry)),
+		Remediation: "Do not expose istiod's monitoring port (15014) outside the cluster, and set ENABLE_DEBUG_ON_HTTP=false on istiod.",
+	})
+	return info
+}
+
+// checkNginxMetrics looks for the ingress-nginx controller's metrics, which name every ingress host and backend service
+func checkNginxMetrics(ctx context.Context, client *http.Client, baseURL string, port int, info *IngressInfo) *IngressInfo {
+	status, body := fetch(ctx, client, baseURL+"/metrics")
+	if status != http.StatusOK || !strings.Contains(string(body), "nginx_ingress_controller_") {
+		return info
+	}
+	version := ""
+	if m := nginxReleaseRegex.FindSubmatch(body); m != nil {
+		version = string(m[1])
+	}
+	info = identified(info, "ingress-nginx", "ingress-nginx", version)
+	info.Issues = append(info.Issues, Issue{
+		Severity:    "medium",
+		Title:       "Exposed NGINX Ingress Controller Metrics",
+		Description: fmt.Sprintf("The ingress-nginx controller's status port %d is reachable from the internet. Its metrics name every ingress host, namespace and backend service, and /healthz and /nginx_status reveal controller internals.", port),
+		Remediation: "Keep the controller's metrics port (10254) internal: do not list it in the controller Service and scrape it from inside the cluster.",
+	})
+	return info
+}
+
+// checkHAProxyStats looks for the HAProxy statistics page, which lists every frontend and backend server
+func checkHAProxyStats(ctx context.Context, client *http.Client, baseURL string, port int, info *IngressInfo) *IngressInfo {
+	for _, path := range []string{"/stats", "/haproxy?stats"} {
+		status, body := fetch(ctx, client, baseURL+path)
+		if status != http.StatusOK || !strings.Contains(string(body), "Statistics Report for HAProxy") {
+			continue
+		}
+		version := ""
+		if m := haproxyVersionRegex.FindSubmatch(body); m != nil {
+			version = string(m[1])
+		}
+		info = identified(info, "haproxy", "HAProxy", version)
+		info.Issues = append(info.Issues, Issue{
+			Severity:    "medium",
+			Title:       "Exposed HAProxy Statistics Page",
+			Description: fmt.Sprintf("The HAProxy statistics page is reachable without authentication at %s on port %d. It lists every frontend, backend server address and health state, and may allow disabling servers if admin mode is on.", path, port),
+			Remediation: "Protect the stats page with 'stats auth', bind it to an internal address, and never enable 'stats admin' on a public listener.",
+		})
+		break
+	}
+	return info
+}
+
+// checkContourDebug looks for Contour's debug server, which renders the whole routing graph
+func checkContourDebug(ctx context.Context, client *http.Client, baseURL string, port int, info *IngressInfo) *IngressInfo {
+	if status, body := fetch(ctx, client, baseURL+"/debug/dag"); status == http.StatusOK && strings.HasPrefix(strings.TrimSpace(string(body)), "digraph") {
+		info = identified(info, "contour", "Contour", "")
+		info.Issues = append(info.Issues, Issue{
+			Severity:    "high",
+			Title:       "Exposed Contour Debug Endpoint",
+			Description: fmt.Sprintf("Contour's debug server is reachable on port %d. /debug/dag renders every HTTPProxy, Ingress, Secret reference and backend Service of the cluster, and /debug/pprof exposes process internals.", port),
+			Remediation: "Keep Contour's debug server on localhost (--debug-http-address=127.0.0.1) and do not publish its port.",
+		})
+		return info
+	}
+
+	status, body := fetch(ctx, client, baseURL+"/metrics")
+	if status != http.StatusOK || !strings.Contains(string(body), "contour_build_info") {
+		return info
+	}
+	version := ""
+	if m := contourVersionRegex.FindSubmatch(body); m != nil {
+		version = string(m[1])
+	}
+	info = identified(info, "contour", "Contour", version)
+	info.Issues = append(info.Issues, Issue{
+		Severity:    "medium",
+		Title:       "Exposed Contour Metrics",
+		Description: fmt.Sprintf("Contour's metrics port %d is reachable from the internet, revealing its version and the number and state of routes it manages.", port),
+		Remediation: "Do not publish Contour's metrics port; scrape it from inside the cluster.",
+	})
+	return info
+}
+
+// checkKongAdmin looks for the Kong Admin API, which can reconfigure every route and plugin
+func checkKongAdmin(ctx context.Context, client *http.Client, baseURL string, port int, info *IngressInfo) *IngressInfo {
+	status, body := fetch(ctx, client, baseURL+"/")
+	var root struct {
+		Version string `json:"version"`
+		Tagline string `json:"tagline"`
+	}
+	if status != http.StatusOK || json.Unmarshal(body, &root) != nil || !strings.Contains(strings.ToLower(root.Tagline), "kong") {
+		return info
+	}
+	info = identified(info, "kong", "Kong", root.Version)
+	info.Issues = append(info.Issues, Issue{
+		Severity:    "critical",
+		Title:       "Exposed Kong Admin API",
+		Description: fmt.Sprintf("The Kong Admin API (version %s) is reachable without authentication on port %d. Anyone can add routes, disable authentication plugins or redirect traffic to their own upstream.", root.Version, port),
+		Remediation: "Bind admin_listen to 127.0.0.1 or an internal network, or protect the Admin API with RBAC (Kong Enterprise) or an authenticated loopback route.",
+	})
+	return info
+}
+
+// identified fills in the controller found by an active check, keeping what the fingerprint already established
+func identified(info *IngressInfo, controller Technology, name, version string) *IngressInfo {
+	if info == nil {
+		info = &IngressInfo{Controller: controller, Name: name}
+	}
+	if info.Version == "" {
+		info.Version = version
+	}
+	return info
+}
+
+func versionRemediation(controller Technology) string {
+	switch controller {
+	case "ingress-nginx":
+		return "Set server-tokens: \"false\" in the ingress-nginx ConfigMap and use a custom default backend."
+	case "traefik", "envoy", "istio":
+		return "Strip or override the Server header at the edge and keep the controller up to date."
+	case "haproxy":
+		return "Remove version details from error files and headers and keep HAProxy up to date."
+	}
+	return "Hide version details in Server headers and error pages and keep the controller up to date."
+}
+
+// fetch performs a GET and returns the status and up to probeMaxBody bytes of the body; status is 0 on error
+func fetch(ctx context.Context, client *http.Client, url string) (int, []byte) {
+	return fetchLimit(ctx, client, url, probeMaxBody)
+}
+
+func fetchLimit(ctx context.Context, client *http.Client, url string, limit int64) (int, []byte) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, nil
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, limit))
+	return resp.StatusCode, body
+}
+
+func hasPort(ports []int, port int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Bounds on what kubelet checks read and report
+const (
+	kubeletMaxPodList    = 4 * 1024 * 1024 // Pod lists of busy nodes are far larger than other probe responses
+	kubeletMaxNamespaces = 20
+	kubeletMaxImages     = 20
+	kubeletMaxImageLen   = 120
+)
+
+var (
+	metricsBuildInfo   = regexp.MustCompile(`kubernetes_build_info\{[^}]*git_version="([^"]+)"`)
+	metricsRunningPods = regexp.MustCompile(`(?m)^kubelet_running_pods?(?:_count)?(?:\{[^}]*\})? (\d+)`)
+	metricsNamespace   = regexp.MustCompile(`\bnamespace="([^"]+)"`)
+)
+
+// podList is the part of a kubelet /pods response reported as evidence; env, args and volumes are never read
+type podList struct {
+	Kind  string `json:"kind"`
+	Items []struct {
+		Metadata struct {
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			InitContainers []struct {
+				Image string `json:"image"`
+			} `json:"initContainers"`
+			Containers []struct {
+				Image string `json:"image"`
+			} `json:"containers"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+// assessKubelet checks what the kubelet serves without credentials on /pods, /spec and /metrics.
+// On the read-only port these are always open; on 10250 they are only open with anonymous auth enabled.
+// pods is the pod list identification read, nil if it was refused.
+func assessKubelet(ctx context.Context, client *http.Client, base string, port int, info *KubernetesInfo, pods []byte) {
+	readOnly := info.Component == KubeletReadOnlyAPI
+
+	// Like the API server, a kubelet reachable from the internet is an exposure even when it authenticates
+	if !readOnly {
+		info.Issues = append(info.Issues, Issue{
+			Severity:    "high",
+			Title:       "Exposed Kubelet API",
+			Description: fmt.Sprintf("The Kubelet API is reachable from the internet on port %d. Any authentication or authorization mistake on the node exposes command execution in its containers.", port),
+			Remediation: "Restrict the kubelet port to the control plane network with firewall rules or security groups.",
+			Evidence:    []string{fmt.Sprintf("GET /pods: %d", info.Endpoints["/pods"])},
+		})
+	}
+
+	if pods != nil {
+		evidence := podEvidence(pods)
+		if readOnly {
+			info.Issues = append(info.Issues, Issue{
+				Severity:    "high",
+				Title:       "Kubernetes Kubelet Read-Only Port Exposed",
+				Description: fmt.Sprintf("The kubelet read-only port %d serves the pods running on the node over plain HTTP without authentication, disclosing namespaces, container images and pod configuration.", port),
+				Remediation: kubeletRemediation(readOnly),
+				Evidence:    evidence,
+			})
+		} else {
+			info.Issues = append(info.Issues, Issue{
+				Severity:    "critical",
+				Title:       "Kubernetes Kubelet API Anonymous Access",
+				Description: fmt.Sprintf("The Kubelet API on port %d allows anonymous users to list pods. The same access allows running commands in any container on the node through /run and /exec.", port),
+				Remediation: kubeletRemediation(readOnly),
+				Evidence:    evidence,
+			})
+		}
+	}
+
+	status, body := fetch(ctx, client, base+"/metrics")
+	info.Endpoints["/metrics"] = status
+	if status == http.StatusOK && strings.Contains(string(body), "# TYPE ") {
+		info.Issues = append(info.Issues, Issue{
+			Severity:    "medium",
+			Title:       "Kubernetes Kubelet Metrics Exposed",
+			Description: fmt.Sprintf("The kubelet on port %d serves its Prometheus metrics without authentication, disclosing its version, workload counts and namespace names.", port),
+			Remediation: kubeletRemediation(readOnly),
+			Evidence:    metricsEvidence(string(body), info),
+		})
+	}
+
+	status, body = fetch(ctx, client, base+"/spec/")
+	info.Endpoints["/spec"] = status
+	if status == http.StatusOK {
+		if evidence, ok := specEvidence(body); ok {
+			info.Issues = append(info.Issues, Issue{
+				Severity:    "low",
+				Title:       "Kubernetes Kubelet Node Specification Exposed",
+				Description: fmt.Sprintf("The kubelet on port %d discloses the node's hardware and cloud instance details without authentication.", port),
+				Remediation: kubeletRemediation(readOnly),
+				Evidence:    evidence,
+			})
+		}
+	}
+}
+
+func kubeletRemediation(readOnly bool) string {
+	if readOnly {
+		return "Set readOnlyPort: 0 in the KubeletConfiguration (--read-only-port=0) and block port 10255 at the firewall."
+	}
+	return "Set --anonymous-auth=false and --authorization-mode=Webhook in Kubelet configuration."
+}
+
+// podEvidence summarizes a pod list as its size, namespaces and container images
+func podEvidence(body []byte) []string {
+	var list podList
+	if err := json.Unmarshal(body, &list); err != nil || list.Kind != "PodList" {
+		return []string{"pod list too large or malformed to summarize"}
+	}
+
+	namespaces := make(map[string]bool)
+	images := make(map[string]bool)
+	for _, pod := range list.Items {
+		namespaces[pod.Metadata.Namespace] = true
+		for _, c := range pod.Spec.InitContainers {
+			images[redactImage(c.Image)] = true
+		}
+		for _, c := range pod.Spec.Containers {
+			images[redactImage(c.Image)] = true
+		}
+	}
+
+	evidence := []string{fmt.Sprintf("%d pods in %d namespaces", len(list.Items), len(namespaces))}
+	if names := boundedList(setKeys(namespaces), kubeletMaxNamespaces); len(names) > 0 {
+		evidence = append(evidence, "namespaces: "+strings.Join(names, ", "))
+	}
+	for _, image := range boundedList(setKeys(images), kubeletMaxImages) {
+		evidence = append(evidence, "image: "+image)
+	}
+	return evidence
+}
+
+// metricsEvidence reports the kubelet version, running pod count and namespaces found in its metrics,
+// and records the version on info if the kubelet had not disclosed it otherwise
+func metricsEvidence(metrics string, info *KubernetesInfo) []string {
+	var evidence []string
+	if m := metricsBuildInfo.FindStringSubmatch(metrics); m != nil {
+		evidence = append(evidence, "kubelet version: "+m[1])
+		if info.Version == "" {
+			info.Version = m[1]
+		}
+	}
+	if m := metricsRunningPods.FindStringSubmatch(metrics); m != nil {
+		evidence = append(evidence, "running pods: "+m[1])
+	}
+	namespaces := make(map[string]bool)
+	for _, m := range metricsNamespace.FindAllStringSubmatch(metrics, -1) {
+		namespaces[m[1]] = true
+	}
+	if names := boundedList(setKeys(namespaces), kubeletMaxNamespaces); len(names) > 0 {
+		evidence = append(evidence, "namespaces: "+strings.Join(names, ", "))
+	}
+	return evidence
+}
+
+// specEvidence reports the machine details of a cAdvisor /spec document. Identifiers are only noted, not reported.
+func specEvidence(body []byte) ([]string, bool) {
+	var spec struct {
+		NumCores       int    `json:"num_cores"`
+		MemoryCapacity uint64 `json:"memory_capacity"`
+		MachineID      string `json:"machine_id"`
+		SystemUUID     string `json:"system_uuid"`
+		CloudProvider  string `json:"cloud_provider"`
+		InstanceType   string `json:"instance_type"`
+		InstanceID     string `json:"instance_id"`
+	}
+	if json.Unmarshal(body, &spec) != nil || spec.NumCores == 0 {
+		return nil, false
+	}
+	evidence := []string{fmt.Sprintf("%d cores, %d MiB memory", spec.NumCores, spec.MemoryCapacity/(1024*1024))}
+	if spec.CloudProvider != "" && spec.CloudProvider != "Unknown" {
+		evidence = append(evidence, fmt.Sprintf("cloud: %s %s", spec.CloudProvider, spec.InstanceType))
+	}
+	if spec.MachineID != "" || spec.SystemUUID != "" || (spec.InstanceID != "" && spec.InstanceID != "None") {
+		evidence = append(evidence, "machine and instance identifiers disclosed (redacted)")
+	}
+	return evidence, true
+}
+
+// redactImage drops credentials embedded in the registry part of an image reference and digests,
+// and shortens what remains
+func redactImage(image string) string {
+	if registry, rest, ok := strings.Cut(image, "/"); ok {
+		if _, host, ok := strings.Cut(registry, "@"); ok {
+			image = "[redacted]@" + host + "/" + rest
+		}
+	}
+	if name, _, ok := strings.Cut(image, "@sha256:"); ok {
+		image = name + "@sha256:..."
+	}
+	if len(image) > kubeletMaxImageLen {
+		image = image[:kubeletMaxImageLen] + "..."
+	}
+	return image
+}
+
+// boundedList returns at most limit entries, noting how many were left out
+func boundedList(list []string, limit int) []string {
+	if len(list) <= limit {
+		return list
+	}
+	return append(list[:limit:limit], fmt.Sprintf("... and %d more", len(list)-limit))
+}
+
+func setKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		if k != "" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,289 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cortex-backend/internal/outbound"
+)
+
+// Kubernetes components that answer on the ports Kubernetes technology is detected on
+const (
+	KubeAPIServer      = "kube-apiserver"
+	KubeletAPI         = "kubelet"
+	KubeletReadOnlyAPI = "kubelet-readonly" // Plaintext port 10255, never authenticated
+)
+
+// KubernetesInfo is what a Kubernetes endpoint disclosed to an anonymous client
+type KubernetesInfo struct {
+	Component string // KubeAPIServer, KubeletAPI or KubeletReadOnlyAPI
+	Scheme    string
+	Version   string // gitVersion, e.g. "v1.29.3"
+	Platform  string
+	// Status code of each discovery endpoint requested anonymously, e.g. "/version" -> 200
+	Endpoints map[string]int
+	// Resources anonymous users can read, e.g. "pods (cluster-wide)" or "get,list configmaps in kube-system"
+	AnonymousReads []string
+	// Whether an anonymous SelfSubjectRulesReview was answered
+	RulesReviewAllowed bool
+	Issues             []Issue
+}
+
+// kubeReadCheck is a cluster-wide list request that only succeeds if anonymous users may read the resource
+type kubeReadCheck struct {
+	resource string
+	path     string
+}
+
+var kubeReadChecks = []kubeReadCheck{
+	{"namespaces", "/api/v1/namespaces"},
+	{"nodes", "/api/v1/nodes"},
+	{"pods", "/api/v1/pods"},
+	{"services", "/api/v1/services"},
+	{"secrets", "/api/v1/secrets"},
+	{"configmaps", "/api/v1/configmaps"},
+	{"serviceaccounts", "/api/v1/serviceaccounts"},
+	{"deployments.apps", "/apis/apps/v1/deployments"},
+	{"clusterrolebindings.rbac.authorization.k8s.io", "/apis/rbac.authorization.k8s.io/v1/clusterrolebindings"},
+}
+
+var podListKind = regexp.MustCompile(`^\s*\{\s*"kind":\s*"PodList"`)
+
+// Namespaces whose anonymous permissions are reviewed with SelfSubjectRulesReview
+var kubeReviewNamespaces = []string{"default", "kube-system"}
+
+// ProbeKubernetes works out whether host:port is a kube-apiserver, a kubelet or the kubelet
+// read-only port, and assesses what it discloses without credentials. Every request is a read
+// (a SelfSubjectRulesReview is evaluated, not stored). Returns nil if neither component answers.
+func ProbeKubernetes(ctx context.Context, host string, port int) *KubernetesInfo {
+	// Accept TLS 1.0 so clusters that only speak legacy protocols are still probed
+	client := &http.Client{
+		Transport: outbound.Transport(&tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS10}),
+		Timeout:   5 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+
+	for _, scheme := range []string{"https", "http"} {
+		base := scheme + "://" + address
+		if info := identifyAPIServer(ctx, client, base); info != nil {
+			info.Scheme = scheme
+			assessAPIServer(ctx, client, base, port, info)
+			return info
+		}
+		if info, pods := identifyKubelet(ctx, client, base, scheme); info != nil {
+			assessKubelet(ctx, client, base, port, info, pods)
+			return info
+		}
+	}
+	return nil
+}
+
+// identifyAPIServer recognizes the API server by its /version document or its Status error objects
+func identifyAPIServer(ctx context.Context, client *http.Client, base string) *KubernetesInfo {
+	status, body := fetch(ctx, client, base+"/version")
+	var version struct {
+		GitVersion string `json:"gitVersion"`
+		Platform   string `json:"platform"`
+	}
+	if status == http.StatusOK && json.Unmarshal(body, &version) == nil && version.GitVersion != "" {
+		return &KubernetesInfo{Component: KubeAPIServer, Version: version.GitVersion, Platform: version.Platform, Endpoints: map[string]int{"/version": status}}
+	}
+	if (status == http.StatusUnauthorized || status == http.StatusForbidden) && isStatusObject(body) {
+		return &KubernetesInfo{Component: KubeAPIServer, Endpoints: map[string]int{"/version": status}}
+	}
+	return nil
+}
+
+// identifyKubelet recognizes the kubelet by its pod list or the plain-text errors of its authorizer,
+// and returns the pod list if it was readable
+func identifyKubelet(ctx context.Context, client *http.Client, base, scheme string) (*KubernetesInfo, []byte) {
+	status, body := fetchLimit(ctx, client, base+"/pods", kubeletMaxPodList)
+	component := KubeletAPI
+	if scheme == "http" {
+		component = KubeletReadOnlyAPI
+	}
+	info := &KubernetesInfo{Component: component, Scheme: scheme, Endpoints: map[string]int{"/pods": status}}
+
+	switch {
+	// Matched rather than decoded: the pod list of a busy node may be cut off at kubeletMaxPodList
+	case status == http.StatusOK && podListKind.Match(body):
+		return info, body
+	case status == http.StatusUnauthorized && strings.TrimSpace(string(body)) == "Unauthorized":
+		return info, nil
+	case status == http.StatusForbidden && strings.Contains(string(body), "user=system:anonymous"):
+		// "Forbidden (user=system:anonymous, verb=get, resource=nodes, subresource=proxy)"
+		return info, nil
+	}
+	return nil, nil
+}
+
+func assessAPIServer(ctx context.Context, client *http.Client, base string, port int, info *KubernetesInfo) {
+	for _, path := range []string{"/healthz", "/api", "/apis"} {
+		status, _ := fetch(ctx, client, base+path)
+		info.Endpoints[path] = status
+	}
+
+	for _, check := range kubeReadChecks {
+		status, body := fetch(ctx, client, base+check.path+"?limit=1")
+		if status == http.StatusOK && !isStatusObject(body) {
+			info.AnonymousReads = append(info.AnonymousReads, check.resource+" (cluster-wide)")
+		}
+	}
+	for _, namespace := range kubeReviewNamespaces {
+		rules, ok := reviewAnonymousRules(ctx, client, base, namespace)
+		if !ok {
+			continue
+		}
+		info.RulesReviewAllowed = true
+		info.AnonymousReads = append(info.AnonymousReads, rules...)
+	}
+	info.AnonymousReads = dedupe(info.AnonymousReads)
+
+	version := "version undisclosed"
+	if info.Version != "" {
+		version = info.Version
+	}
+	var evidence []string
+	for _, path := range []string{"/version", "/healthz", "/api", "/apis"} {
+		evidence = append(evidence, fmt.Sprintf("GET %s: %d", path, info.Endpoints[path]))
+	}
+	info.Issues = append(info.Issues, Issue{
+		Severity:    "high",
+		Title:       "Exposed Kubernetes API",
+		Description: fmt.Sprintf("The Kubernetes API server (%s) is reachable from the internet on port %d. Any credential leak, RBAC mistake or API server vulnerability is directly exploitable.", version, port),
+		Remediation: "Restrict the API server to trusted networks (authorized networks, firewall or private endpoint) and set --anonymous-auth=false where possible.",
+		Evidence:    evidence,
+	})
+
+	if info.Endpoints["/api"] == http.StatusOK || info.Endpoints["/apis"] == http.StatusOK {
+		info.Issues = append(info.Issues, Issue{
+			Severity:    "medium",
+			Title:       "Kubernetes API Discovery Open to Anonymous Users",
+			Description: fmt.Sprintf("Anonymous users can enumerate the API groups and resources served on port %d. The system:discovery role is bound to unauthenticated users, which Kubernetes stopped doing by default in 1.14.", port),
+			Remediation: "Remove system:unauthenticated from the system:discovery and system:basic-user ClusterRoleBindings.",
+			Evidence:    evidence,
+		})
+	}
+
+	if len(info.AnonymousReads) > 0 {
+		description := fmt.Sprintf("Anonymous users hold %d read permissions on the API server on port %d.", len(info.AnonymousReads), port)
+		if readsSecrets(info.AnonymousReads) {
+			description += " This includes Secrets, which hold service account tokens and credentials that lead to full cluster takeover."
+		}
+		info.Issues = append(info.Issues, Issue{
+			Severity:    "critical",
+			Title:       "Kubernetes API Anonymous Resource Access",
+			Description: description,
+			Remediation: "Remove RoleBindings and ClusterRoleBindings that grant permissions to system:anonymous or system:unauthenticated, and set --anonymous-auth=false on the API server.",
+			Evidence:    info.AnonymousReads,
+		})
+	}
+}
+
+// reviewAnonymousRules asks the API server which actions an anonymous user may perform in a namespace
+// and returns the read rules. ok is false when anonymous users may not create the review.
+func reviewAnonymousRules(ctx context.Context, client *http.Client, base, namespace string) ([]string, bool) {
+	review := map[string]interface{}{
+		"apiVersion": "authorization.k8s.io/v1",
+		"kind":       "SelfSubjectRulesReview",
+		"spec":       map[string]string{"namespace": namespace},
+	}
+	payload, _ := json.Marshal(review)
+	req, err := http.NewRequestWithContext(ctx, "POST", base+"/apis/authorization.k8s.io/v1/selfsubjectrulesreviews", bytes.NewReader(payload))
+	if err != nil {
+		return nil, false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, false
+	}
+
+	var result struct {
+		Status struct {
+			ResourceRules []struct {
+				Verbs         []string `json:"verbs"`
+				APIGroups     []string `json:"apiGroups"`
+				Resources     []string `json:"resources"`
+				ResourceNames []string `json:"resourceNames"`
+			} `json:"resourceRules"`
+		} `json:"status"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, probeMaxBody)).Decode(&result); err != nil {
+		return nil, false
+	}
+
+	var reads []string
+	for _, rule := range result.Status.ResourceRules {
+		var verbs []string
+		for _, verb := range rule.Verbs {
+			if verb == "get" || verb == "list" || verb == "watch" || verb == "*" {
+				verbs = append(verbs, verb)
+			}
+		}
+		if len(verbs) == 0 {
+			continue
+		}
+		for _, resource := range rule.Resources {
+			for _, group := range rule.APIGroups {
+				name := resource
+				if group != "" {
+					name += "." + group
+				}
+				entry := fmt.Sprintf("%s %s in %s", strings.Join(verbs, ","), name, namespace)
+				if len(rule.ResourceNames) > 0 {
+					entry += " (only " + strings.Join(rule.ResourceNames, ", ") + ")"
+				}
+				reads = append(reads, entry)
+			}
+		}
+	}
+	return reads, true
+}
+
+func readsSecrets(reads []string) bool {
+	for _, r := range reads {
+		if strings.HasPrefix(r, "secrets ") || strings.Contains(r, " secrets in ") || strings.Contains(r, " * in ") || strings.Contains(r, " *.* in ") {
+			return true
+		}
+	}
+	return false
+}
+
+// isStatusObject reports whether body is a Kubernetes Status object, as returned for API errors
+func isStatusObject(body []byte) bool {
+	var status struct {
+		Kind       string `json:"kind"`
+		APIVersion string `json:"apiVersion"`
+	}
+	return json.Unmarshal(body, &status) == nil && status.Kind == "Status" && status.APIVersion == "v1"
+}
+
+func dedupe(list []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, s := range list {
+		if !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,344 @@
+package container
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"cortex-backend/internal/outbound"
+)
+
+// Bounds keep catalog enumeration to a handful of requests
+const (
+	registryMaxRepositories = 100
+	registryMaxTagLists     = 10
+	registryMaxTags         = 20
+	registryMaxEvidence     = 25
+)
+
+// RegistryInfo is what an OCI/Docker registry disclosed to an anonymous client
+type RegistryInfo struct {
+	Auth         string // "none", "bearer" or "basic"
+	Anonymous    bool   // Readable without credentials, directly or with an anonymous token
+	Repositories []RegistryRepository
+	Truncated    bool // The catalog had more repositories than were listed
+	PushAllowed  bool
+	PushEvidence string // How push permission was established
+	// Push was neither confirmed nor ruled out: an open registry accepts pushes unless it is read-only,
+	// which cannot be told without writing to it
+	PushUnverified bool
+	Issues         []Issue
+}
+
+// RegistryRepository is a repository listed in the catalog
+type RegistryRepository struct {
+	Name string
+	Tags []string // Only fetched for the first repositories
+}
+
+// bearerChallenge is a parsed WWW-Authenticate: Bearer header
+type bearerChallenge struct {
+	realm   string
+	service string
+}
+
+var challengeParamRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// ProbeRegistry checks a registry's /v2/ API for anonymous access, lists its catalog and tags
+// (bounded), and works out whether anonymous push is permitted without writing anything: for
+// token auth it asks for a push scope and inspects what the token grants. Returns nil if the
+// port does not serve the registry API.
+func ProbeRegistry(ctx context.Context, baseURL string, port int) *RegistryInfo {
+	baseURL = strings.TrimRight(baseURL, "/")
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil
+	}
+	client := &http.Client{
+		Transport: outbound.Transport(&tls.Config{InsecureSkipVerify: true}),
+		Timeout:   5 * time.Second,
+	}
+
+	resp, err := registryGet(ctx, client, baseURL+"/v2/", "")
+	if err != nil {
+		return nil
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Docker-Distribution-Api-Version") == "" {
+		return nil
+	}
+
+	info := &RegistryInfo{Auth: "none"}
+	token := ""
+	var challenge *bearerChallenge
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		info.Anonymous = true
+	case resp.StatusCode == http.StatusUnauthorized:
+		header := resp.Header.Get("Www-Authenticate")
+		if !strings.HasPrefix(strings.ToLower(header), "bearer ") {
+			info.Auth = "basic"
+			return info
+		}
+		info.Auth = "bearer"
+		challenge = parseBearerChallenge(header)
+		// The token service must run on the scanned address; other hosts are out of scope
+		if challenge == nil || !sameHost(ctx, challenge.realm, base.Hostname()) {
+			return info
+		}
+		token, _ = anonymousToken(ctx, client, challenge, "registry:catalog:*")
+		if token == "" {
+			return info
+		}
+	default:
+		return nil
+	}
+
+	listCatalog(ctx, client, baseURL, token, challenge, info)
+	// Token services hand out anonymous tokens that grant nothing; only a readable catalog proves access
+	if info.Auth == "bearer" {
+		info.Anonymous = len(info.Repositories) > 0
+	}
+
+	// Push is only reported on a signal that needs no write; an open registry may still be a read-only mirror
+	switch {
+	case info.Auth == "none":
+		info.PushUnverified = true
+	case challenge != nil && len(info.Repositories) > 0:
+		repo := info.Repositories[0].Name
+		scope := "repository:" + repo + ":pull,push"
+		if pushToken, err := anonymousToken(ctx, client, challenge, scope); err == nil && tokenGrants(pushToken, repo, "push") {
+			info.PushAllowed = true
+			info.PushEvidence = fmt.Sprintf("the token service granted an anonymous token with push access to %s", repo)
+		}
+	}
+
+	info.Issues = registryIssues(info, port)
+	return info
+}
+
+// listCatalog fills in repositories from /v2/_catalog and the tags of the first few
+func listCatalog(ctx context.Context, client *http.Client, baseURL, token string, challenge *bearerChallenge, info *RegistryInfo) {
+	var catalog struct {
+		Repositories []string `json:"repositories"`
+	}
+	resp, err := registryGet(ctx, client, fmt.Sprintf("%s/v2/_catalog?n=%d", baseURL, registryMaxRepositories), token)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(io.LimitReader(resp.Body, probeMaxBody)).Decode(&catalog) != nil {
+		return
+	}
+	// A Link header points at the next page
+	info.Truncated = resp.Header.Get("Link") != "" || len(catalog.Repositories) > registryMaxRepositories
+
+	for i, name := range catalog.Repositories {
+		if i == registryMaxRepositories {
+			break
+		}
+		repo := RegistryRepository{Name: name}
+		if i < registryMaxTagLists {
+			repoToken := token
+			if challenge != nil {
+				repoToken, _ = anonymousToken(ctx, client, challenge, "repository:"+name+":pull")
+			}
+			repo.Tags = listTags(ctx, client, baseURL, name, repoToken)
+		}
+		info.Repositories = append(info.Repositories, repo)
+	}
+}
+
+func listTags(ctx context.Context, client *http.Client, baseURL, repo, token string) []string {
+	resp, err := registryGet(ctx, client, fmt.Sprintf("%s/v2/%s/tags/list?n=%d", baseURL, repo, registryMaxTags), token)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	var list struct {
+		Tags []string `json:"tags"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(io.LimitReader(resp.Body, probeMaxBody)).Decode(&list) != nil {
+		return nil
+	}
+	if len(list.Tags) > registryMaxTags {
+		list.Tags = list.Tags[:registryMaxTags]
+	}
+	return list.Tags
+}
+
+func registryIssues(info *RegistryInfo, port int) []Issue {
+	if !info.Anonymous {
+		return nil
+	}
+	var evidence []string
+	for i, repo := range info.Repositories {
+		if i == registryMaxEvidence {
+			evidence = append(evidence, fmt.Sprintf("... and %d more", len(info.Repositories)-registryMaxEvidence))
+			break
+		}
+		entry := repo.Name
+		if len(repo.Tags) > 0 {
+			entry += " (tags: " + strings.Join(repo.Tags, ", ") + ")"
+		}
+		evidence = append(evidence, entry)
+	}
+
+	listed := "Its catalog could not be listed, but images can be pulled by name."
+	if n := len(info.Repositories); n > 0 {
+		more := ""
+		if info.Truncated {
+			more = " or more"
+		}
+		listed = fmt.Sprintf("Its catalog lists %d%s repositories; images often contain source code, credentials and internal hostnames.", n, more)
+	}
+	issues := []Issue{{
+		Severity:    "high",
+		Title:       "Unauthenticated Container Registry",
+		Description: fmt.Sprintf("The container registry on port %d can be read without credentials. %s", port, listed),
+		Remediation: "Require authentication for pulls (auth: htpasswd or token in the distribution config, or private projects in Harbor) and do not expose the registry to the internet.",
+		Evidence:    evidence,
+	}}
+
+	switch {
+	case info.PushAllowed:
+		issues = append(issues, Issue{
+			Severity:    "critical",
+			Title:       "Container Registry Allows Anonymous Push",
+			Description: fmt.Sprintf("The container registry on port %d accepts image pushes without credentials: %s. Attackers can overwrite images that clusters pull and run.", port, info.PushEvidence),
+			Remediation: "Enable authentication with per-repository push permissions, or run the registry read-only (storage.maintenance.readonly) where pushes are not needed.",
+			Evidence:    evidence,
+		})
+	case info.PushUnverified:
+		issues = append(issues, Issue{
+			Severity:    "medium",
+			Title:       "Container Registry Push Access Not Verified",
+			Description: fmt.Sprintf("The container registry on port %d enforces no authentication, so it accepts image pushes unless it runs read-only. Cortex does not write to registries, so whether anonymous push works was not confirmed.", port),
+			Remediation: "Confirm the registry runs read-only (storage.maintenance.readonly) or behind authentication; otherwise anyone can overwrite the images clusters pull.",
+			Evidence:    []string{"GET /v2/ answered 200 without credentials"},
+		})
+	}
+	return issues
+}
+
+func registryGet(ctx context.Context, client *http.Client, url, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return client.Do(req)
+}
+
+func parseBearerChallenge(header string) *bearerChallenge {
+	c := &bearerChallenge{}
+	for _, m := range challengeParamRegex.FindAllStringSubmatch(header, -1) {
+		switch strings.ToLower(m[1]) {
+		case "realm":
+			c.realm = m[2]
+		case "service":
+			c.service = m[2]
+		}
+	}
+	if c.realm == "" {
+		return nil
+	}
+	return c
+}
+
+// sameHost reports whether the token realm is served by the scanned host
+func sameHost(ctx context.Context, realm, host string) bool {
+	u, err := url.Parse(realm)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	if u.Hostname() == host {
+		return true
+	}
+	addrs, err := outbound.LookupHost(ctx, u.Hostname())
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if net.ParseIP(addr).Equal(net.ParseIP(host)) {
+			return true
+		}
+	}
+	return false
+}
+
+// anonymousToken requests a token for scope without credentials, as docker pull does
+func anonymousToken(ctx context.Context, client *http.Client, c *bearerChallenge, scope string) (string, error) {
+	query := url.Values{"scope": {scope}}
+	if c.service != "" {
+		query.Set("service", c.service)
+	}
+	sep := "?"
+	if strings.Contains(c.realm, "?") {
+		sep = "&"
+	}
+	resp, err := registryGet(ctx, client, c.realm+sep+query.Encode(), "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token service returned %d", resp.StatusCode)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// tokenGrants reads the access claim of a JWT registry token (without verifying it)
+// and reports whether it grants action on repository
+func tokenGrants(token, repository, action string) bool {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return false
+	}
+	var claims struct {
+		Access []struct {
+			Type    string   `json:"type"`
+			Name    string   `json:"name"`
+			Actions []string `json:"actions"`
+		} `json:"access"`
+	}
+	if json.Unmarshal(payload, &claims) != nil {
+		return false
+	}
+	for _, a := range claims.Access {
+		if a.Type != "repository" || a.Name != repository {
+			continue
+		}
+		for _, granted := range a.Actions {
+			if granted == action || granted == "*" {
+				return true
+			}
+		}
+	}
+	return false
+}
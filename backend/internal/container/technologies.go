@@ -0,0 +1,366 @@
+package container
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cortex-backend/internal/fingerprinting"
+)
+
+//go:embed technologies.json
+var defaultTechnologies []byte
+
+// MinConfidence is the score a technology needs before it is reported
+const MinConfidence = 50
+
+// TechSignature identifies a technology from an HTTP fingerprint, Wappalyzer-style.
+// Patterns are case-insensitive regular expressions with optional "\;confidence:N"
+// (default 100) and "\;version:\1" suffixes; an empty pattern only requires presence.
+// Ports use the same suffixes. Confidence of matched conditions adds up to at most 100.
+type TechSignature struct {
+	Name        string            `json:"name"`
+	Technology  Technology        `json:"technology"`
+	Category    string            `json:"category"` // "ingress", "dashboard", "orchestration", ...
+	CPE         string            `json:"cpe,omitempty"`
+	Ports       []string          `json:"ports,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Cookies     map[string]string `json:"cookies,omitempty"`
+	Body        []string          `json:"body,omitempty"`
+	Title       []string          `json:"title,omitempty"`
+	Certificate []string          `json:"certificate,omitempty"` // Subject or issuer of the certificate the page was served with
+	Favicon     []int32           `json:"favicon,omitempty"`     // Shodan-compatible mmh3 hashes
+	Implies     []string          `json:"implies,omitempty"`     // Names of technologies this one runs on
+
+	conditions []condition
+}
+
+type condition struct {
+	field      string // "port", "header", "cookie", "body", "title", "certificate" or "favicon"
+	key        string // Header or cookie name
+	re         *regexp.Regexp
+	port       int
+	favicon    int32
+	version    string
+	confidence int
+}
+
+// Match is a technology identified on a service
+type Match struct {
+	Name       string
+	Technology Technology
+	Category   string
+	Version    string
+	CPE        string
+	Confidence int
+}
+
+var (
+	techSignatures     []*TechSignature
+	techSignaturesOnce sync.Once
+
+	backReference = regexp.MustCompile(`\\(\d)`)
+)
+
+// LoadTechSignatures parses a technology signature file
+func LoadTechSignatures(path string) ([]*TechSignature, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseTechSignatures(data)
+}
+
+func parseTechSignatures(data []byte) ([]*TechSignature, error) {
+	var list []*TechSignature
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid technology signature file: %v", err)
+	}
+	for _, sig := range list {
+		if err := sig.compile(); err != nil {
+			return nil, fmt.Errorf("technology signature %s: %v", sig.Name, err)
+		}
+	}
+	return list, nil
+}
+
+func (sig *TechSignature) compile() error {
+	add := func(field, key, spec string) error {
+		pattern, c, err := parseCondition(spec)
+		if err == nil && pattern != "" {
+			c.re, err = regexp.Compile("(?i)" + pattern)
+		}
+		if err != nil {
+			return fmt.Errorf("%s %q: %v", field, spec, err)
+		}
+		c.field, c.key = field, key
+		sig.conditions = append(sig.conditions, c)
+		return nil
+	}
+
+	for _, spec := range sig.Ports {
+		value, c, err := parseCondition(spec)
+		if err == nil {
+			c.port, err = strconv.Atoi(value)
+		}
+		if err != nil {
+			return fmt.Errorf("port %q: %v", spec, err)
+		}
+		c.field = "port"
+		sig.conditions = append(sig.conditions, c)
+	}
+	// Conditions are kept in a stable order so the version is taken from the same place every scan
+	for _, name := range sortedKeys(sig.Headers) {
+		if err := add("header", http.CanonicalHeaderKey(name), sig.Headers[name]); err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedKeys(sig.Cookies) {
+		if err := add("cookie", name, sig.Cookies[name]); err != nil {
+			return err
+		}
+	}
+	for _, list := range []struct {
+		field    string
+		patterns []string
+	}{{"body", sig.Body}, {"title", sig.Title}, {"certificate", sig.Certificate}} {
+		for _, pattern := range list.patterns {
+			if err := add(list.field, "", pattern); err != nil {
+				return err
+			}
+		}
+	}
+	for _, hash := range sig.Favicon {
+		sig.conditions = append(sig.conditions, condition{field: "favicon", favicon: hash, confidence: 100})
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseCondition splits "pattern\;confidence:N\;version:\1" into the pattern and its options
+func parseCondition(spec string) (string, condition, error) {
+	parts := strings.Split(spec, `\;`)
+	c := condition{confidence: 100}
+	for _, opt := range parts[1:] {
+		key, value, _ := strings.Cut(opt, ":")
+		switch key {
+		case "confidence":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 || n > 100 {
+				return "", c, fmt.Errorf("invalid confidence %q", value)
+			}
+			c.confidence = n
+		case "version":
+			c.version = backReference.ReplaceAllString(value, "$${$1}")
+		default:
+			return "", c, fmt.Errorf("unknown option %q", key)
+		}
+	}
+	return parts[0], c, nil
+}
+
+// TechSignatures returns the signatures from TECHNOLOGY_SIGNATURES_PATH if set, otherwise the embedded defaults
+func TechSignatures() []*TechSignature {
+	techSignaturesOnce.Do(func() {
+		if path := os.Getenv("TECHNOLOGY_SIGNATURES_PATH"); path != "" {
+			list, err := LoadTechSignatures(path)
+			if err == nil {
+				techSignatures = list
+				return
+			}
+			log.Printf("[Scan] Failed to load technology signatures from %s: %v", path, err)
+		}
+		techSignatures, _ = parseTechSignatures(defaultTechnologies)
+	})
+	return techSignatures
+}
+
+// Identify returns every technology on a service with at least MinConfidence, most confident first.
+// fp may be nil for services that do not speak HTTP; only port conditions can match then.
+func Identify(port int, fp *fingerprinting.Fingerprint) []Match {
+	target := newMatchTarget(port, fp)
+
+	var matches []Match
+	byName := make(map[string]int)
+	for _, sig := range TechSignatures() {
+		score, version := 0, ""
+		for _, c := range sig.conditions {
+			v, ok := c.match(target)
+			if !ok {
+				continue
+			}
+			score += c.confidence
+			if version == "" {
+				version = v
+			}
+		}
+		if score == 0 {
+			continue
+		}
+		byName[sig.Name] = len(matches)
+		matches = append(matches, newMatch(sig, min(score, 100), version))
+	}
+
+	// A technology implies what it is built on with the same confidence
+	for _, sig := range TechSignatures() {
+		i, ok := byName[sig.Name]
+		if !ok || matches[i].Confidence < MinConfidence {
+			continue
+		}
+		for _, name := range sig.Implies {
+			implied := findTechSignature(name)
+			if implied == nil {
+				continue
+			}
+			if j, ok := byName[name]; ok {
+				matches[j].Confidence = max(matches[j].Confidence, matches[i].Confidence)
+				continue
+			}
+			byName[name] = len(matches)
+			matches = append(matches, newMatch(implied, matches[i].Confidence, ""))
+		}
+	}
+
+	reported := matches[:0]
+	for _, m := range matches {
+		if m.Confidence >= MinConfidence {
+			reported = append(reported, m)
+		}
+	}
+	sort.SliceStable(reported, func(i, j int) bool { return reported[i].Confidence > reported[j].Confidence })
+	return reported
+}
+
+// Primary picks the technology a service is classified by: the most confident match
+func Primary(matches []Match) Technology {
+	if len(matches) == 0 {
+		return Unknown
+	}
+	return matches[0].Technology
+}
+
+func newMatch(sig *TechSignature, confidence int, version string) Match {
+	m := Match{
+		Name:       sig.Name,
+		Technology: sig.Technology,
+		Category:   sig.Category,
+		Version:    version,
+		CPE:        sig.CPE,
+		Confidence: confidence,
+	}
+	if m.CPE != "" && version != "" {
+		m.CPE += ":" + version
+	}
+	return m
+}
+
+func findTechSignature(name string) *TechSignature {
+	for _, sig := range TechSignatures() {
+		if sig.Name == name {
+			return sig
+		}
+	}
+	return nil
+}
+
+// matchTarget holds the parts of a fingerprint conditions are evaluated against
+type matchTarget struct {
+	port        int
+	headers     map[string]string
+	cookies     map[string]string
+	body        string
+	title       string
+	certificate string
+	favicon     int32
+}
+
+func newMatchTarget(port int, fp *fingerprinting.Fingerprint) *matchTarget {
+	t := &matchTarget{port: port}
+	if fp == nil {
+		return t
+	}
+	t.headers = fp.Headers
+	t.cookies = parseCookies(fp.Headers["Set-Cookie"])
+	t.body = string(fp.Body)
+	if t.body == "" {
+		t.body = fp.BodySnippet
+	}
+	t.title = fp.Title
+	if fp.TLS != nil {
+		t.certificate = fp.TLS.Subject + "\n" + fp.TLS.Issuer
+	}
+	t.favicon = fp.FaviconHash
+	return t
+}
+
+// parseCookies reads cookie names and values from Set-Cookie headers joined with ", "
+func parseCookies(header string) map[string]string {
+	cookies := make(map[string]string)
+	for _, part := range strings.Split(header, ", ") {
+		pair, _, _ := strings.Cut(part, ";")
+		name, value, ok := strings.Cut(pair, "=")
+		// Dates in Expires attributes also contain ", "; their pieces have no valid cookie name
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			continue
+		}
+		cookies[name] = value
+	}
+	return cookies
+}
+
+// match reports whether the condition holds and the version it extracted, if any
+func (c condition) match(t *matchTarget) (string, bool) {
+	var value string
+	switch c.field {
+	case "port":
+		return "", t.port == c.port
+	case "favicon":
+		return "", t.favicon != 0 && t.favicon == c.favicon
+	case "header":
+		v, ok := t.headers[c.key]
+		if !ok {
+			return "", false
+		}
+		value = v
+	case "cookie":
+		v, ok := t.cookies[c.key]
+		if !ok {
+			return "", false
+		}
+		value = v
+	case "body":
+		value = t.body
+	case "title":
+		value = t.title
+	case "certificate":
+		value = t.certificate
+	}
+
+	if c.re == nil {
+		return "", value != "" || c.field == "header" || c.field == "cookie"
+	}
+	m := c.re.FindStringSubmatchIndex(value)
+	if m == nil {
+		return "", false
+	}
+	if c.version == "" {
+		return "", true
+	}
+	return strings.TrimSpace(string(c.re.ExpandString(nil, c.version, value, m))), true
+}
@@ -0,0 +1,239 @@
+package container
+
+import (
+	"testing"
+
+	"cortex-backend/internal/fingerprinting"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		spec       string
+		pattern    string
+		confidence int
+		version    string
+		wantErr    bool
+	}{
+		{`^nginx`, `^nginx`, 100, "", false},
+		{`\;confidence:50`, ``, 50, "", false},
+		{`6443\;confidence:25`, `6443`, 25, "", false},
+		{`^Docker/([\d.]+)\;version:\1`, `^Docker/([\d.]+)`, 100, "${1}", false},
+		{`v(\d+)\.(\d+)\;version:\1.\2\;confidence:75`, `v(\d+)\.(\d+)`, 75, "${1}.${2}", false},
+		{`x\;confidence:0`, `x`, 0, "", false},
+		{`x\;confidence:101`, "", 0, "", true},
+		{`x\;confidence:-1`, "", 0, "", true},
+		{`x\;confidence:high`, "", 0, "", true},
+		{`x\;weight:10`, "", 0, "", true},
+	}
+	for _, tt := range tests {
+		pattern, c, err := parseCondition(tt.spec)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseCondition(%q) succeeded, want error", tt.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseCondition(%q): %v", tt.spec, err)
+			continue
+		}
+		if pattern != tt.pattern || c.confidence != tt.confidence || c.version != tt.version {
+			t.Errorf("parseCondition(%q) = %q, confidence %d, version %q; want %q, %d, %q",
+				tt.spec, pattern, c.confidence, c.version, tt.pattern, tt.confidence, tt.version)
+		}
+	}
+}
+
+func TestParseTechSignaturesRejectsInvalid(t *testing.T) {
+	files := []string{
+		`[{"name": "a", "ports": ["http"]}]`,
+		`[{"name": "a", "ports": ["80\\;confidence:200"]}]`,
+		`[{"name": "a", "body": ["("]}]`,
+		`[{"name": "a", "headers": {"Server": "x\\;colour:red"}}]`,
+		`{"name": "a"}`,
+	}
+	for _, data := range files {
+		if _, err := parseTechSignatures([]byte(data)); err == nil {
+			t.Errorf("parseTechSignatures(%s) succeeded, want error", data)
+		}
+	}
+}
+
+func TestEmbeddedTechSignaturesCompile(t *testing.T) {
+	list, err := parseTechSignatures(defaultTechnologies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, sig := range list {
+		names[sig.Name] = true
+	}
+	for _, sig := range list {
+		for _, name := range sig.Implies {
+			if !names[name] {
+				t.Errorf("%s implies unknown technology %q", sig.Name, name)
+			}
+		}
+	}
+}
+
+// find returns the match named name, or nil
+func find(matches []Match, name string) *Match {
+	for i := range matches {
+		if matches[i].Name == name {
+			return &matches[i]
+		}
+	}
+	return nil
+}
+
+func TestIdentifyScoring(t *testing.T) {
+	tests := []struct {
+		name       string
+		port       int
+		fp         *fingerprinting.Fingerprint
+		want       string // Empty when name must not be reported
+		confidence int
+	}{
+		{
+			// A port alone is a hint, never enough to report a technology
+			name: "Kubelet", port: 10250,
+		},
+		{
+			name: "Kubelet", port: 10255,
+			fp:   &fingerprinting.Fingerprint{Body: []byte("404 page not found\n")},
+			want: "Kubelet", confidence: 50,
+		},
+		{
+			name: "Kubelet", port: 10250,
+			fp:   &fingerprinting.Fingerprint{Body: []byte("Unauthorized\n")},
+			want: "Kubelet", confidence: 50,
+		},
+		{
+			// The same body on another port stays below the threshold
+			name: "Kubelet", port: 8080,
+			fp: &fingerprinting.Fingerprint{Body: []byte("404 page not found\n")},
+		},
+		{
+			// Matched conditions add up, but the score is capped at 100
+			name: "Kubernetes API Server", port: 6443,
+			fp: &fingerprinting.Fingerprint{
+				Headers: map[string]string{"Audit-Id": "5f0c", "X-Kubernetes-Pf-Flowschema-Uid": "a1"},
+				Body:    []byte(`{"kind": "Status", "apiVersion": "v1", "message": "forbidden: User \"system:anonymous\""}`),
+			},
+			want: "Kubernetes API Server", confidence: 100,
+		},
+		{
+			name: "Docker Engine API", port: 2375,
+			fp:   &fingerprinting.Fingerprint{Headers: map[string]string{"Api-Version": "1.43"}},
+			want: "Docker Engine API", confidence: 75,
+		},
+	}
+	for _, tt := range tests {
+		matches := Identify(tt.port, tt.fp)
+		m := find(matches, tt.name)
+		if tt.want == "" {
+			if m != nil {
+				t.Errorf("port %d: %s reported with confidence %d, want not reported", tt.port, tt.name, m.Confidence)
+			}
+			continue
+		}
+		if m == nil {
+			t.Errorf("port %d: %s not reported, got %+v", tt.port, tt.name, matches)
+			continue
+		}
+		if m.Confidence != tt.confidence {
+			t.Errorf("port %d: %s confidence = %d, want %d", tt.port, tt.name, m.Confidence, tt.confidence)
+		}
+	}
+}
+
+func TestIdentifyOrdersByConfidence(t *testing.T) {
+	matches := Identify(10250, &fingerprinting.Fingerprint{
+		Headers: map[string]string{"Server": "nginx"},
+		Body:    []byte("404 page not found\n"),
+	})
+	if len(matches) < 2 {
+		t.Fatalf("got %+v, want Nginx and Kubelet", matches)
+	}
+	if matches[0].Name != "Nginx" || Primary(matches) != "nginx" {
+		t.Errorf("primary match = %s, want Nginx", matches[0].Name)
+	}
+	for i := 1; i < len(matches); i++ {
+		if matches[i].Confidence > matches[i-1].Confidence {
+			t.Errorf("matches not ordered by confidence: %+v", matches)
+		}
+	}
+	if Primary(nil) != Unknown {
+		t.Errorf("Primary(nil) = %s, want %s", Primary(nil), Unknown)
+	}
+}
+
+func TestIdentifyImplies(t *testing.T) {
+	// ingress-nginx runs on Nginx: the implied match takes its confidence but no version
+	matches := Identify(443, &fingerprinting.Fingerprint{Body: []byte("default backend - 404")})
+	ingress := find(matches, "ingress-nginx")
+	if ingress == nil || ingress.Confidence != 75 {
+		t.Fatalf("ingress-nginx = %+v, want confidence 75", ingress)
+	}
+	nginx := find(matches, "Nginx")
+	if nginx == nil || nginx.Confidence != 75 || nginx.Version != "" {
+		t.Errorf("implied Nginx = %+v, want confidence 75 and no version", nginx)
+	}
+
+	// A technology matched on its own keeps its version and the higher confidence
+	matches = Identify(443, &fingerprinting.Fingerprint{
+		Headers: map[string]string{"Server": "nginx/1.25.3"},
+		Body:    []byte("default backend - 404"),
+	})
+	nginx = find(matches, "Nginx")
+	if nginx == nil || nginx.Confidence != 100 || nginx.Version != "1.25.3" {
+		t.Errorf("Nginx = %+v, want confidence 100 and version 1.25.3", nginx)
+	}
+
+	// Below the threshold nothing is implied
+	matches = Identify(443, &fingerprinting.Fingerprint{Body: []byte("no default backend here")})
+	if find(matches, "Nginx") != nil {
+		t.Errorf("Nginx implied without ingress-nginx: %+v", matches)
+	}
+}
+
+func TestIdentifyVersionBackReference(t *testing.T) {
+	tests := []struct {
+		name    string
+		port    int
+		fp      *fingerprinting.Fingerprint
+		version string
+		cpe     string
+	}{
+		{
+			name: "Docker Engine API", port: 2375,
+			fp:      &fingerprinting.Fingerprint{Headers: map[string]string{"Server": "Docker/24.0.7 (linux)"}},
+			version: "24.0.7", cpe: "cpe:/a:docker:engine:24.0.7",
+		},
+		{
+			name: "Kubernetes API Server", port: 6443,
+			fp: &fingerprinting.Fingerprint{
+				Headers: map[string]string{"Audit-Id": "5f0c"},
+				Body:    []byte(`{"major": "1", "minor": "29", "gitVersion": "v1.29.2", "platform": "linux/amd64"}`),
+			},
+			version: "1.29.2", cpe: "cpe:/a:kubernetes:kubernetes:1.29.2",
+		},
+		{
+			// An optional group that did not participate leaves the version empty
+			name: "Nginx", port: 80,
+			fp:      &fingerprinting.Fingerprint{Headers: map[string]string{"Server": "nginx"}},
+			version: "", cpe: "cpe:/a:nginx:nginx",
+		},
+	}
+	for _, tt := range tests {
+		m := find(Identify(tt.port, tt.fp), tt.name)
+		if m == nil {
+			t.Errorf("%s not reported", tt.name)
+			continue
+		}
+		if m.Version != tt.version || m.CPE != tt.cpe {
+			t.Errorf("%s version %q, CPE %q; want %q, %q", tt.name, m.Version, m.CPE, tt.version, tt.cpe)
+		}
+	}
+}
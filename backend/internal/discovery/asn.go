@@ -0,0 +1,51 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"cortex-backend/internal/outbound"
+)
+
+// ASNPrefixes returns the prefixes currently announced by an autonomous system via RIPEstat
+func ASNPrefixes(ctx context.Context, asn string) ([]string, error) {
+	asn = strings.ToUpper(strings.TrimSpace(asn))
+	url := fmt.Sprintf("https://stat.ripe.net/data/announced-prefixes/data.json?resource=%s", asn)
+
+	client := &http.Client{Transport: outbound.Transport(nil), Timeout: 15 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("RIPEstat returned status %d for %s", resp.StatusCode, asn)
+	}
+
+	var result struct {
+		Data struct {
+			Prefixes []struct {
+				Prefix string `json:"prefix"`
+			} `json:"prefixes"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	var prefixes []string
+	for _, p := range result.Data.Prefixes {
+		prefixes = append(prefixes, p.Prefix)
+	}
+	return prefixes, nil
+}
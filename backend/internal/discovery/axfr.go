@@ -0,0 +1,182 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"cortex-backend/internal/outbound"
+)
+
+// maxZoneRecords bounds how many transferred records are kept from a single zone
+const maxZoneRecords = 10000
+
+// ZoneTransferResult represents a nameserver that allowed an anonymous AXFR
+type ZoneTransferResult struct {
+	Nameserver  string
+	Address     string
+	RecordCount int
+	Truncated   bool     // The zone had more than maxZoneRecords records; the rest were not read
+	Hosts       []Result // Hosts under the root domain found in the zone
+}
+
+// CheckZoneTransfers attempts an AXFR against every authoritative nameserver of the domain.
+// Nameserver addresses rejected by allow are not contacted; a nil allow permits every address.
+func (s *Scanner) CheckZoneTransfers(ctx context.Context, rootDomain string, allow AddressFilter) []ZoneTransferResult {
+	resp, err := s.query(ctx, rootDomain, dns.TypeNS)
+	if err != nil {
+		return nil
+	}
+
+	var results []ZoneTransferResult
+	for _, rr := range resp.Answer {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+		nsHost := strings.TrimSuffix(ns.Ns, ".")
+
+		for _, ip := range s.resolveHost(ctx, nsHost) {
+			if allow != nil && !allow(nsHost, ip) {
+				continue
+			}
+			addr := net.JoinHostPort(ip, s.DNSPort)
+			records, truncated, err := TransferZone(ctx, rootDomain, addr)
+			if err != nil || len(records) == 0 {
+				continue
+			}
+			if truncated {
+				log.Printf("[Discovery] Zone transfer of %s from %s stopped after %d records", rootDomain, addr, len(records))
+			}
+
+			results = append(results, ZoneTransferResult{
+				Nameserver:  nsHost,
+				Address:     addr,
+				RecordCount: len(records),
+				Truncated:   truncated,
+				Hosts:       zoneHosts(rootDomain, records),
+			})
+			break // One successful transfer per nameserver is enough evidence
+		}
+	}
+	return results
+}
+
+// TransferZone requests a full zone transfer for zone from the nameserver at addr (host:port).
+// Reading stops after maxZoneRecords records; truncated reports whether the zone had more.
+func TransferZone(ctx context.Context, zone string, addr string) (records []dns.RR, truncated bool, err error) {
+	m := new(dns.Msg)
+	m.SetAxfr(dns.Fqdn(zone))
+
+	conn, err := outbound.Dial(ctx, "tcp", addr, 5*time.Second)
+	if err != nil {
+		return nil, false, err
+	}
+	t := &dns.Transfer{
+		Conn:        &dns.Conn{Conn: conn},
+		ReadTimeout: 10 * time.Second,
+	}
+	envelopes, err := t.In(m, addr)
+	if err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	// Closing the connection makes the transfer goroutine fail its read and close the channel;
+	// draining it lets the goroutine exit however the loop below ends
+	defer func() {
+		conn.Close()
+		for range envelopes {
+		}
+	}()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	for env := range envelopes {
+		if ctx.Err() != nil {
+			return nil, false, ctx.Err()
+		}
+		if env.Error != nil {
+			return nil, false, fmt.Errorf("zone transfer from %s failed: %v", addr, env.Error)
+		}
+		records = append(records, env.RR...)
+		if len(records) >= maxZoneRecords {
+			return records[:maxZoneRecords], true, nil
+		}
+	}
+	return records, false, nil
+}
+
+// AddressFilter reports whether a resolved address of host may be contacted
+type AddressFilter func(host, ip string) bool
+
+// HostFilter reports whether a subdomain label of the root domain ("" for the apex) may be contacted
+type HostFilter func(subdomain string) bool
+
+// resolveHost returns the IPv4 and IPv6 addresses of a host using the scanner's nameserver
+func (s *Scanner) resolveHost(ctx context.Context, host string) []string {
+	var ips []string
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		resp, err := s.query(ctx, host, qtype)
+		if err != nil {
+			continue
+		}
+		for _, rr := range resp.Answer {
+			switch v := rr.(type) {
+			case *dns.A:
+				ips = append(ips, v.A.String())
+			case *dns.AAAA:
+				ips = append(ips, v.AAAA.String())
+			}
+		}
+	}
+	return ips
+}
+
+// zoneHosts groups transferred address and alias records into discovery results
+func zoneHosts(rootDomain string, records []dns.RR) []Result {
+	root := strings.ToLower(dns.Fqdn(rootDomain))
+	hostMap := make(map[string][]string)
+	var order []string
+
+	for _, rr := range records {
+		name := strings.ToLower(rr.Header().Name)
+		if strings.Contains(name, "*") || (name != root && !strings.HasSuffix(name, "."+root)) {
+			continue
+		}
+
+		var ip string
+		switch v := rr.(type) {
+		case *dns.A:
+			ip = v.A.String()
+		case *dns.AAAA:
+			ip = v.AAAA.String()
+		case *dns.CNAME:
+			// Aliases are kept without IPs so they are still checked for takeover
+		default:
+			continue
+		}
+
+		sub := strings.TrimSuffix(strings.TrimSuffix(name, root), ".")
+		if _, seen := hostMap[sub]; !seen {
+			order = append(order, sub)
+			hostMap[sub] = nil
+		}
+		if ip != "" {
+			hostMap[sub] = append(hostMap[sub], ip)
+		}
+	}
+
+	var results []Result
+	for _, sub := range order {
+		results = append(results, Result{
+			Subdomain: sub,
+			IPs:       hostMap[sub],
+		})
+	}
+	return results
+}
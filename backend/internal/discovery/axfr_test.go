@@ -0,0 +1,101 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// startZoneServer serves AXFR of example.com on a local TCP port and refuses every other zone
+func startZoneServer(t *testing.T) string {
+	t.Helper()
+	zone := []string{
+		"example.com. 3600 IN SOA ns1.example.com. admin.example.com. 1 7200 3600 1209600 3600",
+		"example.com. 3600 IN NS ns1.example.com.",
+		"www.example.com. 3600 IN A 192.0.2.10",
+		"vpn.example.com. 3600 IN AAAA 2001:db8::10",
+		"shop.example.com. 3600 IN CNAME shops.myshopify.com.",
+		"example.com. 3600 IN SOA ns1.example.com. admin.example.com. 1 7200 3600 1209600 3600",
+	}
+	var records []dns.RR
+	for _, s := range zone {
+		rr, err := dns.NewRR(s)
+		if err != nil {
+			t.Fatalf("invalid test record %q: %v", s, err)
+		}
+		records = append(records, rr)
+	}
+
+	mux := dns.NewServeMux()
+	mux.HandleFunc(".", func(w dns.ResponseWriter, r *dns.Msg) {
+		if r.Question[0].Qtype != dns.TypeAXFR || r.Question[0].Name != "example.com." {
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeRefused)
+			w.WriteMsg(m)
+			return
+		}
+		ch := make(chan *dns.Envelope)
+		tr := new(dns.Transfer)
+		go tr.Out(w, r, ch)
+		ch <- &dns.Envelope{RR: records}
+		close(ch)
+		w.Hijack()
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	started := make(chan struct{})
+	server := &dns.Server{Listener: listener, Handler: mux, NotifyStartedFunc: func() { close(started) }}
+	go server.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { server.Shutdown() })
+	return listener.Addr().String()
+}
+
+func TestTransferZone(t *testing.T) {
+	addr := startZoneServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	records, truncated, err := TransferZone(ctx, "example.com", addr)
+	if err != nil {
+		t.Fatalf("TransferZone: %v", err)
+	}
+	if truncated {
+		t.Error("small zone reported as truncated")
+	}
+	if len(records) != 6 {
+		t.Fatalf("got %d records, want 6", len(records))
+	}
+
+	hosts := zoneHosts("example.com", records)
+	got := make(map[string][]string)
+	for _, h := range hosts {
+		got[h.Subdomain] = h.IPs
+	}
+	if ips := got["www"]; len(ips) != 1 || ips[0] != "192.0.2.10" {
+		t.Errorf("www: got %v, want [192.0.2.10]", ips)
+	}
+	if ips := got["vpn"]; len(ips) != 1 || ips[0] != "2001:db8::10" {
+		t.Errorf("vpn: got %v, want [2001:db8::10]", ips)
+	}
+	if _, ok := got["shop"]; !ok {
+		t.Error("CNAME shop was not kept for takeover checks")
+	}
+}
+
+func TestTransferZoneRefused(t *testing.T) {
+	addr := startZoneServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	records, _, err := TransferZone(ctx, "other.test", addr)
+	if err == nil {
+		t.Fatalf("refused transfer returned %d records and no error", len(records))
+	}
+}
@@ -0,0 +1,169 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"cortex-backend/internal/outbound"
+)
+
+const (
+	// certSpotterMaxPages bounds a single incremental poll; the cursor lets the next run pick up the rest
+	certSpotterMaxPages = 20
+	// certSpotterMaxSeekPages bounds the walk to the newest issuance when seeding a cursor
+	certSpotterMaxSeekPages = 500
+)
+
+// ctTimeout reads the Certificate Transparency query budget from CT_TIMEOUT (e.g. "90s"), defaulting to 60s
+func ctTimeout() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("CT_TIMEOUT")); err == nil && d > 0 {
+		return d
+	}
+	return 60 * time.Second
+}
+
+// FetchCTHistory downloads every certificate ever logged for rootDomain from crt.sh and
+// returns the subdomain labels they name. This is slow for large domains and is meant for the first run only.
+func (s *Scanner) FetchCTHistory(ctx context.Context, rootDomain string) ([]string, error) {
+	endpoint := fmt.Sprintf("https://crt.sh/?q=%%.%s&output=json", rootDomain)
+
+	client := &http.Client{Transport: outbound.Transport(nil), Timeout: s.CTTimeout}
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var crtResults []struct {
+		NameValue string `json:"name_value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&crtResults); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, res := range crtResults {
+		// crt.sh often returns wildcards or multiple names per entry
+		names = append(names, strings.Split(res.NameValue, "\n")...)
+	}
+	return subdomainLabels(rootDomain, names), nil
+}
+
+// FetchCTUpdates returns subdomain labels from certificates logged after cursor, using the
+// Cert Spotter issuances API, along with the cursor to resume from next time. An empty cursor
+// starts from the oldest unexpired certificate. CERTSPOTTER_API_KEY raises the anonymous rate limit.
+func (s *Scanner) FetchCTUpdates(ctx context.Context, rootDomain string, cursor string) ([]string, string, error) {
+	client := &http.Client{Transport: outbound.Transport(nil), Timeout: s.CTTimeout}
+	var names []string
+
+	for page := 0; page < certSpotterMaxPages; page++ {
+		issuances, err := certSpotterPage(ctx, client, rootDomain, cursor, true)
+		if err != nil {
+			// Keep what was fetched; the cursor only advances past pages we have read
+			return subdomainLabels(rootDomain, names), cursor, err
+		}
+		if len(issuances) == 0 {
+			break
+		}
+		for _, iss := range issuances {
+			names = append(names, iss.DNSNames...)
+		}
+		cursor = issuances[len(issuances)-1].ID
+	}
+
+	return subdomainLabels(rootDomain, names), cursor, nil
+}
+
+// LatestCTCursor returns the Cert Spotter cursor past the newest certificate logged for rootDomain,
+// so polling after a full crt.sh download starts where that history ends. Cert Spotter cannot list
+// newest-first, so this walks the issuance IDs without their names, which keeps each page small.
+func (s *Scanner) LatestCTCursor(ctx context.Context, rootDomain string) (string, error) {
+	client := &http.Client{Transport: outbound.Transport(nil), Timeout: s.CTTimeout}
+	cursor := ""
+
+	for page := 0; page < certSpotterMaxSeekPages; page++ {
+		issuances, err := certSpotterPage(ctx, client, rootDomain, cursor, false)
+		if err != nil {
+			return cursor, err
+		}
+		if len(issuances) == 0 {
+			return cursor, nil
+		}
+		cursor = issuances[len(issuances)-1].ID
+	}
+	return cursor, fmt.Errorf("more than %d pages of Cert Spotter issuances for %s", certSpotterMaxSeekPages, rootDomain)
+}
+
+type certSpotterIssuance struct {
+	ID       string   `json:"id"`
+	DNSNames []string `json:"dns_names"`
+}
+
+// certSpotterPage requests one page of issuances logged after cursor, with their DNS names if expand is set
+func certSpotterPage(ctx context.Context, client *http.Client, rootDomain, cursor string, expand bool) ([]certSpotterIssuance, error) {
+	params := url.Values{}
+	params.Set("domain", rootDomain)
+	params.Set("include_subdomains", "true")
+	if expand {
+		params.Set("expand", "dns_names")
+	}
+	if cursor != "" {
+		params.Set("after", cursor)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.certspotter.com/v1/issuances?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if key := os.Getenv("CERTSPOTTER_API_KEY"); key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Cert Spotter returned status %d", resp.StatusCode)
+	}
+
+	var issuances []certSpotterIssuance
+	if err := json.NewDecoder(resp.Body).Decode(&issuances); err != nil {
+		return nil, err
+	}
+	return issuances, nil
+}
+
+// subdomainLabels reduces certificate names to unique subdomain labels of rootDomain, skipping wildcards
+func subdomainLabels(rootDomain string, names []string) []string {
+	seen := make(map[string]bool)
+	var subs []string
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || strings.Contains(name, "*") {
+			continue
+		}
+		// Only keep subdomains of the root
+		if !strings.HasSuffix(name, "."+rootDomain) {
+			continue
+		}
+		sub := strings.TrimSuffix(name, "."+rootDomain)
+		if !seen[sub] {
+			seen[sub] = true
+			subs = append(subs, sub)
+		}
+	}
+	return subs
+}
@@ -2,18 +2,20 @@ package discovery
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"net"
-	"net/http"
-	"strings"
 	"sync"
 	"time"
+
+	"cortex-backend/internal/outbound"
 )
 
 // Scanner handles asset discovery for a domain
 type Scanner struct {
-	CommonSubdomains []string
+	CommonSubdomains     []string
+	Nameserver           string // Resolver used for raw DNS queries (host:port)
+	DNSPort              string // Port used when querying authoritative nameservers directly
+	TakeoverFingerprints []TakeoverFingerprint
+	CTTimeout            time.Duration // Budget for Certificate Transparency log queries
 }
 
 func NewScanner() *Scanner {
@@ -24,6 +26,10 @@ func NewScanner() *Scanner {
 			"git", "gitlab", "jenkins", "docker", "k8s", "kube",
 			"registry", "vault", "vpn", "mail", "remote",
 		},
+		Nameserver:           systemNameserver(),
+		DNSPort:              "53",
+		TakeoverFingerprints: loadTakeoverFingerprints(),
+		CTTimeout:            ctTimeout(),
 	}
 }
 
@@ -50,7 +56,7 @@ func (s *Scanner) EnumerateSubdomains(ctx context.Context, rootDomain string) ([
 			defer func() { <-semaphore }()
 
 			fullDomain := fmt.Sprintf("%s.%s", sub, rootDomain)
-			ips, err := net.LookupHost(fullDomain)
+			ips, err := outbound.LookupHost(ctx, fullDomain)
 			if err == nil && len(ips) > 0 {
 				mu.Lock()
 				results = append(results, Result{
@@ -66,7 +72,7 @@ func (s *Scanner) EnumerateSubdomains(ctx context.Context, rootDomain string) ([
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		ips, err := net.LookupHost(rootDomain)
+		ips, err := outbound.LookupHost(ctx, rootDomain)
 		if err == nil && len(ips) > 0 {
 			mu.Lock()
 			results = append(results, Result{
@@ -80,52 +86,24 @@ func (s *Scanner) EnumerateSubdomains(ctx context.Context, rootDomain string) ([
 	wg.Wait()
 	return results, nil
 }
+
 // PassiveDiscovery finds subdomains via crt.sh (Certificate Transparency logs)
 func (s *Scanner) PassiveDiscovery(ctx context.Context, rootDomain string) ([]Result, error) {
-	url := fmt.Sprintf("https://crt.sh/?q=%%.%s&output=json", rootDomain)
-	
-	client := &http.Client{Timeout: 15 * time.Second}
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	subs, err := s.FetchCTHistory(ctx, rootDomain)
 	if err != nil {
 		return nil, err
 	}
+	return s.ResolveSubdomains(ctx, rootDomain, subs), nil
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var crtResults []struct {
-		NameValue string `json:"name_value"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&crtResults); err != nil {
-		return nil, err
-	}
-
-	uniqueSubs := make(map[string]bool)
-	for _, res := range crtResults {
-		// crt.sh often returns wildcards or multiple names per entry
-		names := strings.Split(res.NameValue, "\n")
-		for _, name := range names {
-			name = strings.TrimSpace(name)
-			if name == "" || strings.Contains(name, "*") {
-				continue
-			}
-			// Only keep subdomains of the root
-			if strings.HasSuffix(name, rootDomain) && name != rootDomain {
-				sub := strings.TrimSuffix(name, "."+rootDomain)
-				uniqueSubs[sub] = true
-			}
-		}
-	}
-
+// ResolveSubdomains resolves subdomain labels of rootDomain, keeping those that have addresses
+func (s *Scanner) ResolveSubdomains(ctx context.Context, rootDomain string, subs []string) []Result {
 	var results []Result
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, 5) // Low concurrency for DNS resolution
 
-	for sub := range uniqueSubs {
+	for _, sub := range subs {
 		wg.Add(1)
 		go func(sub string) {
 			defer wg.Done()
@@ -133,7 +111,7 @@ func (s *Scanner) PassiveDiscovery(ctx context.Context, rootDomain string) ([]Re
 			defer func() { <-semaphore }()
 
 			fullDomain := fmt.Sprintf("%s.%s", sub, rootDomain)
-			ips, err := net.LookupHost(fullDomain)
+			ips, err := outbound.LookupHost(ctx, fullDomain)
 			if err == nil && len(ips) > 0 {
 				mu.Lock()
 				results = append(results, Result{
@@ -146,5 +124,5 @@ func (s *Scanner) PassiveDiscovery(ctx context.Context, rootDomain string) ([]Re
 	}
 
 	wg.Wait()
-	return results, nil
+	return results
 }
@@ -0,0 +1,351 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"cortex-backend/internal/outbound"
+)
+
+// CommonDKIMSelectors are probed because DKIM selectors cannot be enumerated
+var CommonDKIMSelectors = []string{
+	"default", "dkim", "mail", "selector1", "selector2", "google",
+	"k1", "k2", "s1", "s2", "smtp", "mandrill", "mxvault",
+}
+
+// EmailSecurityIssue represents a missing or permissive email authentication policy
+type EmailSecurityIssue struct {
+	Severity    string // "high", "medium", "low"
+	Title       string
+	Description string
+	Remediation string
+}
+
+// EmailSecurityReport summarizes the email authentication posture of a root domain
+type EmailSecurityReport struct {
+	HasMX         bool
+	SPF           string
+	DMARC         string
+	DKIMSelectors []string
+	MTASTSMode    string
+	TLSRPT        string
+	Issues        []EmailSecurityIssue
+}
+
+// CheckEmailSecurity parses SPF, DMARC, common DKIM selectors and MTA-STS/TLS-RPT policies.
+// Everything is read from DNS except the MTA-STS policy, which is only fetched from mta-sts.<domain>
+// if allowHost and allowAddress accept it; nil filters permit everything.
+func (s *Scanner) CheckEmailSecurity(ctx context.Context, rootDomain string, allowHost HostFilter, allowAddress AddressFilter) *EmailSecurityReport {
+	report := &EmailSecurityReport{}
+
+	if resp, err := s.query(ctx, rootDomain, dns.TypeMX); err == nil {
+		for _, rr := range resp.Answer {
+			if _, ok := rr.(*dns.MX); ok {
+				report.HasMX = true
+				break
+			}
+		}
+	}
+
+	s.checkSPF(ctx, rootDomain, report)
+	s.checkDMARC(ctx, rootDomain, report)
+
+	// DKIM, MTA-STS and TLS-RPT only matter for domains that receive or send mail
+	if report.HasMX {
+		s.checkDKIM(ctx, rootDomain, report)
+		s.checkMTASTS(ctx, rootDomain, report, allowHost, allowAddress)
+	}
+
+	return report
+}
+
+func (s *Scanner) checkSPF(ctx context.Context, rootDomain string, report *EmailSecurityReport) {
+	var spfRecords []string
+	for _, txt := range s.lookupTXT(ctx, rootDomain) {
+		if strings.HasPrefix(strings.ToLower(txt), "v=spf1") {
+			spfRecords = append(spfRecords, txt)
+		}
+	}
+
+	if len(spfRecords) == 0 {
+		report.addIssue("medium", "Missing SPF Record",
+			fmt.Sprintf("%s does not publish an SPF record, so any server can send mail claiming to be from this domain.", rootDomain),
+			"Publish a TXT record such as \"v=spf1 include:<your-provider> -all\", or \"v=spf1 -all\" if the domain never sends mail.")
+		return
+	}
+	if len(spfRecords) > 1 {
+		report.addIssue("medium", "Multiple SPF Records",
+			fmt.Sprintf("%s publishes %d SPF records. Receivers treat this as a permanent error and ignore SPF entirely.", rootDomain, len(spfRecords)),
+			"Merge all SPF mechanisms into a single v=spf1 TXT record.")
+	}
+
+	spf := spfRecords[0]
+	report.SPF = spf
+
+	lookups := 0
+	allQualifier := ""
+	for _, term := range strings.Fields(strings.ToLower(spf))[1:] {
+		mechanism := strings.TrimLeft(term, "+-~?")
+		switch {
+		case mechanism == "all":
+			allQualifier = "+"
+			if strings.ContainsAny(term[:1], "+-~?") {
+				allQualifier = term[:1]
+			}
+		case strings.HasPrefix(mechanism, "include:"), strings.HasPrefix(mechanism, "exists:"),
+			strings.HasPrefix(mechanism, "redirect="), mechanism == "a", strings.HasPrefix(mechanism, "a:"),
+			strings.HasPrefix(mechanism, "a/"), mechanism == "mx", strings.HasPrefix(mechanism, "mx:"),
+			strings.HasPrefix(mechanism, "mx/"), mechanism == "ptr", strings.HasPrefix(mechanism, "ptr:"):
+			lookups++
+		}
+	}
+
+	switch allQualifier {
+	case "+":
+		report.addIssue("high", "Permissive SPF Policy",
+			fmt.Sprintf("The SPF record of %s ends in \"+all\", which authorizes every server on the internet to send mail for the domain.", rootDomain),
+			"Replace \"+all\" with \"-all\" (or \"~all\" while migrating) so only listed senders are authorized.")
+	case "?":
+		report.addIssue("medium", "Neutral SPF Policy",
+			fmt.Sprintf("The SPF record of %s ends in \"?all\", so receivers take no action against unauthorized senders.", rootDomain),
+			"Replace \"?all\" with \"-all\" (or \"~all\" while migrating).")
+	case "":
+		if !strings.Contains(strings.ToLower(spf), "redirect=") {
+			report.addIssue("medium", "SPF Policy Without All Mechanism",
+				fmt.Sprintf("The SPF record of %s has no \"all\" mechanism, so mail from unlisted servers is treated as neutral.", rootDomain),
+				"Terminate the SPF record with \"-all\" (or \"~all\" while migrating).")
+		}
+	}
+
+	if lookups > 10 {
+		report.addIssue("medium", "SPF Lookup Limit Exceeded",
+			fmt.Sprintf("The SPF record of %s requires %d DNS lookups at the top level. More than 10 causes a permanent error and SPF is ignored.", rootDomain, lookups),
+			"Flatten includes or remove unused senders to stay within 10 DNS lookups.")
+	}
+}
+
+func (s *Scanner) checkDMARC(ctx context.Context, rootDomain string, report *EmailSecurityReport) {
+	for _, txt := range s.lookupTXT(ctx, "_dmarc."+rootDomain) {
+		if strings.HasPrefix(strings.ToLower(txt), "v=dmarc1") {
+			report.DMARC = txt
+			break
+		}
+	}
+
+	if report.DMARC == "" {
+		report.addIssue("medium", "Missing DMARC Record",
+			fmt.Sprintf("%s does not publish a DMARC policy, so receivers have no instruction to reject spoofed mail and you receive no reports.", rootDomain),
+			fmt.Sprintf("Publish a TXT record at _dmarc.%s such as \"v=DMARC1; p=quarantine; rua=mailto:dmarc@%s\" and move to p=reject once reports are clean.", rootDomain, rootDomain))
+		return
+	}
+
+	tags := parseTagList(report.DMARC)
+	switch strings.ToLower(tags["p"]) {
+	case "reject", "quarantine":
+	case "none":
+		report.addIssue("medium", "DMARC Policy Not Enforced",
+			fmt.Sprintf("The DMARC policy of %s is p=none, which only monitors and does not stop spoofed mail from being delivered.", rootDomain),
+			"Move the DMARC policy to p=quarantine and then p=reject once legitimate senders pass SPF/DKIM alignment.")
+	default:
+		report.addIssue("medium", "Invalid DMARC Policy",
+			fmt.Sprintf("The DMARC record of %s has no valid p= tag, so receivers ignore it.", rootDomain),
+			"Set the p= tag to none, quarantine or reject.")
+	}
+
+	if pct, ok := tags["pct"]; ok {
+		if n, err := strconv.Atoi(pct); err == nil && n < 100 {
+			report.addIssue("low", "Partial DMARC Enforcement",
+				fmt.Sprintf("The DMARC policy of %s applies to only %d%% of failing mail.", rootDomain, n),
+				"Remove the pct= tag or set it to 100 once enforcement is validated.")
+		}
+	}
+
+	if tags["rua"] == "" {
+		report.addIssue("low", "DMARC Reporting Not Configured",
+			fmt.Sprintf("The DMARC record of %s has no rua= address, so spoofing attempts go unnoticed.", rootDomain),
+			"Add an aggregate report address, e.g. rua=mailto:dmarc@"+rootDomain+".")
+	}
+}
+
+func (s *Scanner) checkDKIM(ctx context.Context, rootDomain string, report *EmailSecurityReport) {
+	for _, selector := range CommonDKIMSelectors {
+		for _, txt := range s.lookupTXT(ctx, selector+"._domainkey."+rootDomain) {
+			tags := parseTagList(txt)
+			key, ok := tags["p"]
+			if !ok {
+				continue
+			}
+			if key == "" {
+				continue // Revoked key
+			}
+			report.DKIMSelectors = append(report.DKIMSelectors, selector)
+
+			der, err := base64.StdEncoding.DecodeString(key)
+			if err != nil {
+				continue
+			}
+			pub, err := x509.ParsePKIXPublicKey(der)
+			if err != nil {
+				continue
+			}
+			if rsaKey, ok := pub.(*rsa.PublicKey); ok {
+				bits := rsaKey.N.BitLen()
+				if bits < 1024 {
+					report.addIssue("high", "Weak DKIM Key",
+						fmt.Sprintf("DKIM selector %s of %s uses a %d-bit RSA key, which can be factored to forge signed mail.", selector, rootDomain, bits),
+						"Rotate the selector to a 2048-bit RSA or Ed25519 key.")
+				} else if bits < 2048 {
+					report.addIssue("low", "Short DKIM Key",
+						fmt.Sprintf("DKIM selector %s of %s uses a %d-bit RSA key. 2048 bits is the current recommendation.", selector, rootDomain, bits),
+						"Rotate the selector to a 2048-bit RSA or Ed25519 key.")
+				}
+			}
+			break
+		}
+	}
+
+	if len(report.DKIMSelectors) == 0 {
+		report.addIssue("low", "No DKIM Selector Found",
+			fmt.Sprintf("None of the common DKIM selectors are published for %s. Mail may be sent unsigned, weakening DMARC alignment.", rootDomain),
+			"Enable DKIM signing with your mail provider and publish the selector's public key.")
+	}
+}
+
+func (s *Scanner) checkMTASTS(ctx context.Context, rootDomain string, report *EmailSecurityReport, allowHost HostFilter, allowAddress AddressFilter) {
+	hasSTS := false
+	for _, txt := range s.lookupTXT(ctx, "_mta-sts."+rootDomain) {
+		if strings.HasPrefix(strings.ToLower(txt), "v=stsv1") {
+			hasSTS = true
+			break
+		}
+	}
+
+	if !hasSTS {
+		report.addIssue("low", "MTA-STS Not Configured",
+			fmt.Sprintf("%s does not publish an MTA-STS policy, so inbound mail can be downgraded to plaintext by an attacker on the network path.", rootDomain),
+			fmt.Sprintf("Publish _mta-sts.%s TXT \"v=STSv1; id=<serial>\" and serve a policy at https://mta-sts.%s/.well-known/mta-sts.txt.", rootDomain, rootDomain))
+	} else if s.policyHostAllowed(ctx, rootDomain, allowHost, allowAddress) {
+		mode, err := fetchMTASTSMode(ctx, rootDomain)
+		if err != nil {
+			report.addIssue("medium", "MTA-STS Policy Unreachable",
+				fmt.Sprintf("%s advertises MTA-STS but the policy file could not be fetched: %v", rootDomain, err),
+				fmt.Sprintf("Serve the policy at https://mta-sts.%s/.well-known/mta-sts.txt with a valid certificate.", rootDomain))
+		} else {
+			report.MTASTSMode = mode
+			if mode != "enforce" {
+				report.addIssue("low", "MTA-STS Not Enforced",
+					fmt.Sprintf("The MTA-STS policy of %s is in %q mode, so TLS failures do not block delivery.", rootDomain, mode),
+					"Switch the policy to mode: enforce once TLS-RPT reports show no failures.")
+			}
+		}
+	}
+
+	for _, txt := range s.lookupTXT(ctx, "_smtp._tls."+rootDomain) {
+		if strings.HasPrefix(strings.ToLower(txt), "v=tlsrptv1") {
+			report.TLSRPT = txt
+			break
+		}
+	}
+	if report.TLSRPT == "" {
+		report.addIssue("low", "TLS-RPT Not Configured",
+			fmt.Sprintf("%s does not publish a TLS-RPT record, so delivery failures caused by TLS problems go unreported.", rootDomain),
+			fmt.Sprintf("Publish _smtp._tls.%s TXT \"v=TLSRPTv1; rua=mailto:tlsrpt@%s\".", rootDomain, rootDomain))
+	}
+}
+
+// policyHostAllowed reports whether the scope permits fetching the policy from mta-sts.<domain>.
+// A name that does not resolve is allowed, so the fetch fails and the policy is reported unreachable.
+func (s *Scanner) policyHostAllowed(ctx context.Context, rootDomain string, allowHost HostFilter, allowAddress AddressFilter) bool {
+	if allowHost != nil && !allowHost("mta-sts") {
+		return false
+	}
+	host := "mta-sts." + rootDomain
+	allowed := true
+	if allowAddress != nil {
+		for _, ip := range s.resolveHost(ctx, host) {
+			// Every address is checked so each excluded one is reported
+			if !allowAddress(host, ip) {
+				allowed = false
+			}
+		}
+	}
+	return allowed
+}
+
+// fetchMTASTSMode downloads the MTA-STS policy and returns its mode
+func fetchMTASTSMode(ctx context.Context, rootDomain string) (string, error) {
+	client := &http.Client{Transport: outbound.Transport(nil), Timeout: 5 * time.Second}
+	url := fmt.Sprintf("https://mta-sts.%s/.well-known/mta-sts.txt", rootDomain)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("policy returned status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(io.LimitReader(resp.Body, 64*1024))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if found && strings.TrimSpace(strings.ToLower(key)) == "mode" {
+			return strings.TrimSpace(strings.ToLower(value)), nil
+		}
+	}
+	return "", fmt.Errorf("policy has no mode")
+}
+
+// lookupTXT returns the TXT strings of name, joining multi-string records
+func (s *Scanner) lookupTXT(ctx context.Context, name string) []string {
+	resp, err := s.query(ctx, name, dns.TypeTXT)
+	if err != nil {
+		return nil
+	}
+
+	var records []string
+	for _, rr := range resp.Answer {
+		if txt, ok := rr.(*dns.TXT); ok {
+			records = append(records, strings.TrimSpace(strings.Join(txt.Txt, "")))
+		}
+	}
+	return records
+}
+
+// parseTagList parses "k=v; k2=v2" records used by DMARC and DKIM
+func parseTagList(record string) map[string]string {
+	tags := make(map[string]string)
+	for _, part := range strings.Split(record, ";") {
+		key, value, found := strings.Cut(part, "=")
+		if !found {
+			continue
+		}
+		tags[strings.ToLower(strings.TrimSpace(key))] = strings.Join(strings.Fields(value), "")
+	}
+	return tags
+}
+
+func (r *EmailSecurityReport) addIssue(severity, title, description, remediation string) {
+	r.Issues = append(r.Issues, EmailSecurityIssue{
+		Severity:    severity,
+		Title:       title,
+		Description: description,
+		Remediation: remediation,
+	})
+}
@@ -0,0 +1,64 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"cortex-backend/internal/outbound"
+)
+
+const queryTimeout = 5 * time.Second
+
+// systemNameserver returns the first resolver from /etc/resolv.conf, falling back to a public resolver
+func systemNameserver() string {
+	conf, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil || len(conf.Servers) == 0 {
+		return "8.8.8.8:53"
+	}
+	return net.JoinHostPort(conf.Servers[0], conf.Port)
+}
+
+// query sends a single recursive DNS query to the scanner's nameserver. Queries use TCP through the
+// outbound dialer, so they leave through the same proxy or egress addresses as the probes.
+func (s *Scanner) query(ctx context.Context, name string, qtype uint16) (*dns.Msg, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.RecursionDesired = true
+
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	conn, err := outbound.Default().DialContext(ctx, "tcp", s.Nameserver)
+	if err != nil {
+		return nil, err
+	}
+	co := &dns.Conn{Conn: conn}
+	defer co.Close()
+	co.SetDeadline(time.Now().Add(queryTimeout))
+
+	client := &dns.Client{Net: "tcp", Timeout: queryTimeout}
+	in, _, err := client.ExchangeWithConnContext(ctx, m, co)
+	return in, err
+}
+
+// ReverseLookup returns the first PTR name of an IP address, or an empty string if none is published
+func (s *Scanner) ReverseLookup(ctx context.Context, ip string) string {
+	arpa, err := dns.ReverseAddr(ip)
+	if err != nil {
+		return ""
+	}
+
+	resp, err := s.query(ctx, arpa, dns.TypePTR)
+	if err != nil {
+		return ""
+	}
+	for _, rr := range resp.Answer {
+		if ptr, ok := rr.(*dns.PTR); ok {
+			return strings.TrimSuffix(ptr.Ptr, ".")
+		}
+	}
+	return ""
+}
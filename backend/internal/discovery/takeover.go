@@ -0,0 +1,258 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"cortex-backend/internal/outbound"
+)
+
+//go:embed takeover_fingerprints.json
+var defaultTakeoverFingerprints []byte
+
+// TakeoverFingerprint describes a hosting service that is prone to subdomain takeover
+type TakeoverFingerprint struct {
+	Service      string   `json:"service"`
+	CNAMEs       []string `json:"cname"`                   // CNAME target suffixes that belong to the service
+	CNAMEPattern []string `json:"cname_pattern,omitempty"` // Regular expressions for targets that embed a region or account
+	NXDomain     bool     `json:"nxdomain"`                // A dangling CNAME target returns NXDOMAIN
+	Fingerprints []string `json:"fingerprint"`             // Response body signatures of an unclaimed resource
+	Remediation  string   `json:"remediation"`
+
+	patterns []*regexp.Regexp
+}
+
+// TakeoverResult represents a subdomain whose CNAME points to an unclaimed resource
+type TakeoverResult struct {
+	Subdomain   string
+	Chain       []string // CNAME targets in resolution order
+	Service     string
+	Evidence    string
+	Remediation string
+}
+
+// LoadTakeoverFingerprints reads a fingerprint database from a JSON file
+func LoadTakeoverFingerprints(path string) ([]TakeoverFingerprint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTakeoverFingerprints(data)
+}
+
+func parseTakeoverFingerprints(data []byte) ([]TakeoverFingerprint, error) {
+	var fps []TakeoverFingerprint
+	if err := json.Unmarshal(data, &fps); err != nil {
+		return nil, fmt.Errorf("invalid takeover fingerprint file: %v", err)
+	}
+	for i := range fps {
+		for _, pattern := range fps[i].CNAMEPattern {
+			re, err := regexp.Compile("(?i)" + pattern)
+			if err != nil {
+				return nil, fmt.Errorf("takeover fingerprint %s: cname_pattern %q: %v", fps[i].Service, pattern, err)
+			}
+			fps[i].patterns = append(fps[i].patterns, re)
+		}
+	}
+	return fps, nil
+}
+
+// loadTakeoverFingerprints uses TAKEOVER_FINGERPRINTS_PATH if set, otherwise the embedded database
+func loadTakeoverFingerprints() []TakeoverFingerprint {
+	if path := os.Getenv("TAKEOVER_FINGERPRINTS_PATH"); path != "" {
+		fps, err := LoadTakeoverFingerprints(path)
+		if err == nil {
+			return fps
+		}
+		log.Printf("[Discovery] Failed to load takeover fingerprints from %s: %v", path, err)
+	}
+
+	fps, _ := parseTakeoverFingerprints(defaultTakeoverFingerprints)
+	return fps
+}
+
+// CNAMEChain follows CNAME records starting at name and reports whether the final target is NXDOMAIN
+func (s *Scanner) CNAMEChain(ctx context.Context, name string) ([]string, bool, error) {
+	var chain []string
+	current := dns.Fqdn(name)
+
+	// Bound the chain to avoid loops between misconfigured records
+	for i := 0; i < 10; i++ {
+		resp, err := s.query(ctx, current, dns.TypeCNAME)
+		if err != nil {
+			return chain, false, err
+		}
+
+		target := ""
+		for _, rr := range resp.Answer {
+			if cname, ok := rr.(*dns.CNAME); ok && strings.EqualFold(cname.Hdr.Name, current) {
+				target = cname.Target
+				break
+			}
+		}
+		if target == "" {
+			break
+		}
+		chain = append(chain, strings.TrimSuffix(strings.ToLower(target), "."))
+		current = target
+	}
+
+	if len(chain) == 0 {
+		return nil, false, nil
+	}
+
+	resp, err := s.query(ctx, current, dns.TypeA)
+	if err != nil {
+		return chain, false, err
+	}
+	return chain, resp.Rcode == dns.RcodeNameError, nil
+}
+
+// CheckTakeovers follows the CNAME chain of each subdomain and matches it against the takeover fingerprints.
+// Response bodies are only fetched from hosts whose addresses allow accepts; a nil allow permits every address.
+func (s *Scanner) CheckTakeovers(ctx context.Context, rootDomain string, subdomains []string, allow AddressFilter) []TakeoverResult {
+	var results []TakeoverResult
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	semaphore := make(chan struct{}, 10)
+
+	for _, sub := range subdomains {
+		wg.Add(1)
+		go func(sub string) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			fullDomain := rootDomain
+			if sub != "" {
+				fullDomain = fmt.Sprintf("%s.%s", sub, rootDomain)
+			}
+
+			chain, nxdomain, err := s.CNAMEChain(ctx, fullDomain)
+			if err != nil || len(chain) == 0 {
+				return
+			}
+
+			fp := s.matchTakeoverFingerprint(chain)
+			if fp == nil {
+				return
+			}
+
+			evidence := ""
+			if nxdomain && fp.NXDomain {
+				evidence = fmt.Sprintf("CNAME target %s returns NXDOMAIN", chain[len(chain)-1])
+			} else if len(fp.Fingerprints) > 0 && s.addressesAllowed(ctx, fullDomain, allow) {
+				body := fetchTakeoverBody(ctx, fullDomain)
+				for _, sig := range fp.Fingerprints {
+					if strings.Contains(body, sig) {
+						evidence = fmt.Sprintf("Response body contains %q", sig)
+						break
+					}
+				}
+			}
+			if evidence == "" {
+				return
+			}
+
+			mu.Lock()
+			results = append(results, TakeoverResult{
+				Subdomain:   sub,
+				Chain:       chain,
+				Service:     fp.Service,
+				Evidence:    evidence,
+				Remediation: fp.Remediation,
+			})
+			mu.Unlock()
+		}(sub)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// matchTakeoverFingerprint returns the first fingerprint whose CNAME suffix or pattern matches a target in the chain
+func (s *Scanner) matchTakeoverFingerprint(chain []string) *TakeoverFingerprint {
+	for i := range s.TakeoverFingerprints {
+		fp := &s.TakeoverFingerprints[i]
+		for _, target := range chain {
+			if fp.matches(target) {
+				return fp
+			}
+		}
+	}
+	return nil
+}
+
+// matches reports whether a CNAME target belongs to the service. Suffixes only match on a label
+// boundary, so evilgithub.io does not match github.io.
+func (fp *TakeoverFingerprint) matches(target string) bool {
+	for _, suffix := range fp.CNAMEs {
+		suffix = strings.ToLower(strings.TrimPrefix(suffix, "."))
+		if target == suffix || strings.HasSuffix(target, "."+suffix) {
+			return true
+		}
+	}
+	for _, re := range fp.patterns {
+		if re.MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
+
+// addressesAllowed reports whether host resolves and allow accepts all of its addresses
+func (s *Scanner) addressesAllowed(ctx context.Context, host string, allow AddressFilter) bool {
+	ips := s.resolveHost(ctx, host)
+	if len(ips) == 0 {
+		return false
+	}
+	if allow == nil {
+		return true
+	}
+	allowed := true
+	for _, ip := range ips {
+		// Every address is checked so each excluded one is reported
+		if !allow(host, ip) {
+			allowed = false
+		}
+	}
+	return allowed
+}
+
+// fetchTakeoverBody retrieves the start of the page served for a host, trying HTTPS before HTTP
+func fetchTakeoverBody(ctx context.Context, host string) string {
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: outbound.Transport(&tls.Config{InsecureSkipVerify: true}), // Unclaimed resources rarely have a valid cert
+	}
+
+	for _, scheme := range []string{"https://", "http://"} {
+		req, err := http.NewRequestWithContext(ctx, "GET", scheme+host+"/", nil)
+		if err != nil {
+			continue
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			continue
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		resp.Body.Close()
+		return string(body)
+	}
+	return ""
+}
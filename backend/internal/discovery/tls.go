@@ -1,43 +1,330 @@
 package discovery
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
 	"time"
+
+	"cortex-backend/internal/outbound"
 )
 
-// ExtractSANs connects to a domain via TLS and retrieves Subject Alternative Names
-func ExtractSANs(domain string) ([]string, error) {
+// CertificateInfo describes the leaf certificate a TLS service presents and how well its chain holds up
+type CertificateInfo struct {
+	Subject            string
+	Issuer             string
+	NotBefore          time.Time
+	NotAfter           time.Time
+	KeyType            string // "RSA", "ECDSA", "Ed25519"
+	KeyBits            int
+	SignatureAlgorithm string
+	SANs               []string
+	SelfSigned         bool
+	ChainComplete      bool   // Chain verifies against system roots using only the intermediates the server sent
+	ChainError         string // Why the chain did not verify, if it did not
+	FingerprintSHA256  string
+}
+
+// TLSIssue represents a certificate problem worth reporting
+type TLSIssue struct {
+	Severity    string // "high", "medium", "low"
+	Title       string
+	Description string
+	Remediation string
+}
+
+// InspectTLS performs a TLS handshake with ip:port and analyzes the certificate chain presented.
+// serverName is sent as SNI so virtual hosts return the right certificate; it may be empty for bare IPs.
+// It returns an error if the port does not speak TLS.
+func InspectTLS(ctx context.Context, ip string, port int, serverName string) (*CertificateInfo, error) {
+	var peerCerts []*x509.Certificate
 	config := &tls.Config{
 		InsecureSkipVerify: true, // We only want the cert info, no exploitation
+		ServerName:         serverName,
+		// Capture the chain before the handshake finishes, so servers that
+		// demand a client certificate (e.g. Docker on 2376) are still inspected
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			peerCerts = cs.PeerCertificates
+			return nil
+		},
 	}
 
-	dialer := &net.Dialer{
-		Timeout: 5 * time.Second,
+	conn, err := outbound.DialTLS(ctx, net.JoinHostPort(ip, strconv.Itoa(port)), 5*time.Second, config)
+	if err == nil {
+		conn.Close()
+	}
+	if len(peerCerts) == 0 {
+		if err == nil {
+			err = fmt.Errorf("no certificate presented")
+		}
+		return nil, err
 	}
 
-	conn, err := tls.DialWithDialer(dialer, "tcp", fmt.Sprintf("%s:443", domain), config)
+	return analyzeChain(peerCerts), nil
+}
+
+func analyzeChain(chain []*x509.Certificate) *CertificateInfo {
+	leaf := chain[0]
+	sum := sha256.Sum256(leaf.Raw)
+
+	info := &CertificateInfo{
+		Subject:            leaf.Subject.String(),
+		Issuer:             leaf.Issuer.String(),
+		NotBefore:          leaf.NotBefore,
+		NotAfter:           leaf.NotAfter,
+		SignatureAlgorithm: leaf.SignatureAlgorithm.String(),
+		SANs:               certificateNames(leaf),
+		FingerprintSHA256:  hex.EncodeToString(sum[:]),
+	}
+
+	switch key := leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		info.KeyType, info.KeyBits = "RSA", key.N.BitLen()
+	case *ecdsa.PublicKey:
+		info.KeyType, info.KeyBits = "ECDSA", key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		info.KeyType, info.KeyBits = "Ed25519", 256
+	default:
+		info.KeyType = "unknown"
+	}
+
+	info.SelfSigned = string(leaf.RawSubject) == string(leaf.RawIssuer) && leaf.CheckSignatureFrom(leaf) == nil
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	// Verify as of issuance so an expired leaf does not mask a missing intermediate; expiry is reported separately
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Intermediates: intermediates,
+		CurrentTime:   leaf.NotBefore.Add(time.Minute),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	info.ChainComplete = err == nil
 	if err != nil {
-		return nil, err
+		info.ChainError = err.Error()
+	}
+	return info
+}
+
+// certificateNames returns the DNS names a certificate covers, including the CN of legacy certs without SANs
+func certificateNames(cert *x509.Certificate) []string {
+	seen := make(map[string]bool)
+	var names []string
+	candidates := cert.DNSNames
+	if len(candidates) == 0 && cert.Subject.CommonName != "" {
+		candidates = []string{cert.Subject.CommonName}
+	}
+	for _, name := range candidates {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Issues returns the expiry, trust and key strength problems of a certificate as of now
+func (c *CertificateInfo) Issues(now time.Time) []TLSIssue {
+	var issues []TLSIssue
+	subject := c.Subject
+	if len(c.SANs) > 0 {
+		subject = c.SANs[0]
+	}
+
+	daysLeft := int(c.NotAfter.Sub(now).Hours() / 24)
+	switch {
+	case now.After(c.NotAfter):
+		issues = append(issues, TLSIssue{
+			Severity:    "high",
+			Title:       "Expired TLS Certificate",
+			Description: fmt.Sprintf("The certificate for %s expired on %s. Clients will refuse the connection or users will be trained to click through warnings.", subject, c.NotAfter.Format("2006-01-02")),
+			Remediation: "Renew the certificate and automate renewal (e.g. ACME/cert-manager).",
+		})
+	case daysLeft <= 7:
+		issues = append(issues, TLSIssue{
+			Severity:    "high",
+			Title:       "TLS Certificate Expiring Soon",
+			Description: fmt.Sprintf("The certificate for %s expires on %s (%d days left).", subject, c.NotAfter.Format("2006-01-02"), daysLeft),
+			Remediation: "Renew the certificate now and automate renewal (e.g. ACME/cert-manager).",
+		})
+	case daysLeft <= 30:
+		issues = append(issues, TLSIssue{
+			Severity:    "medium",
+			Title:       "TLS Certificate Expiring Soon",
+			Description: fmt.Sprintf("The certificate for %s expires on %s (%d days left).", subject, c.NotAfter.Format("2006-01-02"), daysLeft),
+			Remediation: "Renew the certificate and verify that automated renewal is working.",
+		})
+	}
+
+	if c.SelfSigned {
+		issues = append(issues, TLSIssue{
+			Severity:    "medium",
+			Title:       "Self-Signed TLS Certificate",
+			Description: fmt.Sprintf("The service presents a self-signed certificate (%s). Clients cannot authenticate it, which invites man-in-the-middle attacks and usually indicates an internal service exposed by mistake.", c.Subject),
+			Remediation: "Use a certificate issued by a public CA, or restrict the service to internal networks.",
+		})
+	}
+
+	if (c.KeyType == "RSA" && c.KeyBits < 2048) || (c.KeyType == "ECDSA" && c.KeyBits < 256) {
+		issues = append(issues, TLSIssue{
+			Severity:    "high",
+			Title:       "Weak TLS Certificate Key",
+			Description: fmt.Sprintf("The certificate for %s uses a %d-bit %s key, which can be factored or brute-forced with modest resources.", subject, c.KeyBits, c.KeyType),
+			Remediation: "Reissue the certificate with at least a 2048-bit RSA or 256-bit ECDSA key.",
+		})
+	}
+
+	return issues
+}
+
+// TLSAssessment summarizes the protocol versions and cipher suites a TLS endpoint accepts
+type TLSAssessment struct {
+	Versions    []string // Accepted protocol versions, oldest first, e.g. "TLS 1.0"
+	WeakCiphers []string // Accepted suites that are broken (RC4, 3DES) or lack forward secrecy or AEAD
+	Grade       string   // "A+", "A", "B", "C", "D" or "F"
+}
+
+var tlsVersions = []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+// AssessTLS checks which TLS versions (1.0–1.3) and weak cipher suites an endpoint accepts and grades it:
+// F for RC4/3DES or no TLS 1.2+, D for TLS 1.0, C for TLS 1.1, B for CBC or static RSA suites,
+// A+ for TLS 1.3 with nothing weak, A for TLS 1.2 alone with nothing weak.
+// It returns nil if no handshake succeeds.
+func AssessTLS(ctx context.Context, ip string, port int, serverName string) *TLSAssessment {
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+	assessment := &TLSAssessment{}
+
+	var highestPre13 uint16
+	modern := false
+	for _, v := range tlsVersions {
+		if _, ok := tryHandshake(ctx, address, serverName, v, v, allCipherSuites()); ok {
+			assessment.Versions = append(assessment.Versions, tls.VersionName(v))
+			if v != tls.VersionTLS13 {
+				highestPre13 = v
+			}
+			if v >= tls.VersionTLS12 {
+				modern = true
+			}
+		}
+	}
+	if len(assessment.Versions) == 0 {
+		return nil
+	}
+
+	// Cipher suites are only negotiable up to TLS 1.2; enumerate accepted weak ones by offering them alone
+	broken := false
+	if highestPre13 != 0 {
+		remaining := weakCipherSuites()
+		for len(remaining) > 0 {
+			suite, ok := tryHandshake(ctx, address, serverName, tls.VersionTLS10, highestPre13, remaining)
+			if !ok {
+				break
+			}
+			name := tls.CipherSuiteName(suite)
+			assessment.WeakCiphers = append(assessment.WeakCiphers, name)
+			if strings.Contains(name, "RC4") || strings.Contains(name, "3DES") {
+				broken = true
+			}
+			remaining = removeSuite(remaining, suite)
+		}
+	}
+
+	switch {
+	case broken || !modern:
+		assessment.Grade = "F"
+	case assessment.accepts("TLS 1.0"):
+		assessment.Grade = "D"
+	case assessment.accepts("TLS 1.1"):
+		assessment.Grade = "C"
+	case len(assessment.WeakCiphers) > 0:
+		assessment.Grade = "B"
+	case assessment.accepts("TLS 1.3"):
+		assessment.Grade = "A+"
+	default:
+		assessment.Grade = "A"
+	}
+	return assessment
+}
+
+// LegacyVersions returns the deprecated protocol versions (TLS 1.0 and 1.1) the endpoint accepts
+func (a *TLSAssessment) LegacyVersions() []string {
+	var legacy []string
+	for _, v := range a.Versions {
+		if v == "TLS 1.0" || v == "TLS 1.1" {
+			legacy = append(legacy, v)
+		}
+	}
+	return legacy
+}
+
+func (a *TLSAssessment) accepts(version string) bool {
+	for _, v := range a.Versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// tryHandshake attempts a handshake limited to the given versions and suites, returning the negotiated suite
+func tryHandshake(ctx context.Context, address, serverName string, minVersion, maxVersion uint16, suites []uint16) (uint16, bool) {
+	conn, err := outbound.DialTLS(ctx, address, 5*time.Second, &tls.Config{
+		InsecureSkipVerify: true,
+		ServerName:         serverName,
+		MinVersion:         minVersion,
+		MaxVersion:         maxVersion,
+		CipherSuites:       suites,
+	})
+	if err != nil {
+		return 0, false
 	}
 	defer conn.Close()
+	return conn.ConnectionState().CipherSuite, true
+}
 
-	var sans []string
-	state := conn.ConnectionState()
-	for _, cert := range state.PeerCertificates {
-		sans = append(sans, cert.DNSNames...)
+// allCipherSuites offers every suite Go implements so version probes succeed against legacy-only servers
+func allCipherSuites() []uint16 {
+	var ids []uint16
+	for _, s := range tls.CipherSuites() {
+		ids = append(ids, s.ID)
 	}
+	for _, s := range tls.InsecureCipherSuites() {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
 
-	// Remove duplicates
-	uniqueSANs := make(map[string]bool)
-	var result []string
-	for _, name := range sans {
-		if !uniqueSANs[name] {
-			uniqueSANs[name] = true
-			result = append(result, name)
+// weakCipherSuites returns the pre-TLS 1.3 suites that are broken, use CBC mode or static RSA key exchange
+func weakCipherSuites() []uint16 {
+	var ids []uint16
+	for _, s := range tls.InsecureCipherSuites() {
+		ids = append(ids, s.ID)
+	}
+	for _, s := range tls.CipherSuites() {
+		if strings.Contains(s.Name, "_CBC_") || strings.HasPrefix(s.Name, "TLS_RSA_") {
+			ids = append(ids, s.ID)
 		}
 	}
+	return ids
+}
 
-	return result, nil
+func removeSuite(suites []uint16, suite uint16) []uint16 {
+	var rest []uint16
+	for _, s := range suites {
+		if s != suite {
+			rest = append(rest, s)
+		}
+	}
+	return rest
 }
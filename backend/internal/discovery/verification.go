@@ -1,15 +1,70 @@
 package discovery
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"math/big"
 	"net"
+	"net/http"
+	"os"
+	"regexp"
 	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+
+	"cortex-backend/internal/outbound"
+)
+
+// Domain ownership verification methods, stored on the domain once one succeeds
+const (
+	MethodDNSTXT       = "dns_txt"
+	MethodCNAME        = "cname"
+	MethodHTTPFile     = "http_file"
+	MethodHTMLMeta     = "html_meta"
+	MethodParentDomain = "parent_domain" // Checked by the caller against the org's verified domains
 )
 
+// VerificationMethods lists the methods CheckVerification can test, in the order they are tried
+var VerificationMethods = []string{MethodDNSTXT, MethodCNAME, MethodHTTPFile, MethodHTMLMeta}
+
+// verificationLookupTimeout bounds each DNS lookup of an ownership check
+const verificationLookupTimeout = 10 * time.Second
+
+var metaTagPattern = regexp.MustCompile(`(?i)<meta\s+[^>]*name=["']cortex-verification["'][^>]*>`)
+var metaContentPattern = regexp.MustCompile(`(?i)content=["']([^"']+)["']`)
+
+// CheckVerification tests a single ownership verification method for a domain
+func CheckVerification(method, domain, token string) (bool, error) {
+	switch method {
+	case MethodDNSTXT:
+		return VerifyDomain(domain, token)
+	case MethodCNAME:
+		return VerifyCNAME(domain, token)
+	case MethodHTTPFile:
+		return VerifyHTTPFile(domain, token)
+	case MethodHTMLMeta:
+		return VerifyHTMLMeta(domain, token)
+	}
+	return false, fmt.Errorf("unsupported verification method %q", method)
+}
+
+// VerificationCNAMETarget returns where _cortex.<domain> must point, under VERIFICATION_CNAME_DOMAIN (default verify.cortex.sh)
+func VerificationCNAMETarget(token string) string {
+	base := os.Getenv("VERIFICATION_CNAME_DOMAIN")
+	if base == "" {
+		base = "verify.cortex.sh"
+	}
+	return token + "." + strings.Trim(base, ".")
+}
+
 // VerifyDomain checks if a specific verification token exists in the DNS TXT records for a domain.
 // Standard SaaS practice: check for cortex-verification=TOKEN
 func VerifyDomain(domain, token string) (bool, error) {
-	txtrecords, err := net.LookupTXT(domain)
+	ctx, cancel := context.WithTimeout(context.Background(), verificationLookupTimeout)
+	defer cancel()
+	txtrecords, err := outbound.Resolver().LookupTXT(ctx, domain)
 	if err != nil {
 		return false, fmt.Errorf("failed to lookup TXT records: %v", err)
 	}
@@ -23,3 +78,125 @@ func VerifyDomain(domain, token string) (bool, error) {
 
 	return false, nil
 }
+
+// VerifyCNAME checks that _cortex.<domain> is a CNAME to <token>.<verification domain>
+func VerifyCNAME(domain, token string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), verificationLookupTimeout)
+	defer cancel()
+	cname, err := outbound.Resolver().LookupCNAME(ctx, "_cortex."+domain)
+	if err != nil {
+		return false, nil // No record is a failed check, not an error
+	}
+	return strings.EqualFold(strings.TrimSuffix(cname, "."), VerificationCNAMETarget(token)), nil
+}
+
+// VerifyHTTPFile checks https://<domain>/.well-known/cortex-verification.txt (falling back to http) for the token
+func VerifyHTTPFile(domain, token string) (bool, error) {
+	for _, scheme := range []string{"https", "http"} {
+		body, err := fetchVerificationPage(scheme+"://"+domain+"/.well-known/cortex-verification.txt", domain)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(body, "\n") {
+			line = strings.TrimSpace(line)
+			if line == token || line == "cortex-verification="+token {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// VerifyHTMLMeta checks the home page for <meta name="cortex-verification" content="TOKEN">
+func VerifyHTMLMeta(domain, token string) (bool, error) {
+	for _, scheme := range []string{"https", "http"} {
+		body, err := fetchVerificationPage(scheme+"://"+domain+"/", domain)
+		if err != nil {
+			continue
+		}
+		for _, tag := range metaTagPattern.FindAllString(body, -1) {
+			if m := metaContentPattern.FindStringSubmatch(tag); m != nil && strings.TrimSpace(m[1]) == token {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// fetchVerificationPage GETs a URL without following redirects off the domain being verified,
+// so an open redirect elsewhere cannot vouch for it
+func fetchVerificationPage(url, domain string) (string, error) {
+	client := &http.Client{
+		Transport: outbound.Transport(nil),
+		Timeout:   10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 || !strings.EqualFold(req.URL.Hostname(), domain) {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return string(body), err
+}
+
+// rangeVerificationBlockBits is the size of the blocks a range is verified in: 256 addresses,
+// a /24 reverse zone for IPv4 and a /120 nibble zone for IPv6
+const rangeVerificationBlockBits = 8
+
+// RangeVerificationNames returns the reverse-DNS names where the owner of an IP range publishes its token,
+// one per 256-address block, e.g. _cortex-verification.0.113.0.203.in-addr.arpa for 203.0.113.0/24.
+// Reverse zones are delegated per block, so holding one block's zone does not verify its neighbours.
+func RangeVerificationNames(cidr string) ([]string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := network.Mask.Size()
+	blocks := 1
+	if hostBits := bits - ones; hostBits > rangeVerificationBlockBits {
+		blocks = 1 << (hostBits - rangeVerificationBlockBits)
+	}
+
+	names := make([]string, 0, blocks)
+	block := new(big.Int).SetBytes(network.IP)
+	step := new(big.Int).Lsh(big.NewInt(1), rangeVerificationBlockBits)
+	for i := 0; i < blocks; i++ {
+		ip := make(net.IP, len(network.IP))
+		block.FillBytes(ip)
+		arpa, err := dns.ReverseAddr(ip.String())
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, "_cortex-verification."+strings.TrimSuffix(arpa, "."))
+		block.Add(block, step)
+	}
+	return names, nil
+}
+
+// VerifyIPRange checks for the verification token in the reverse zone of every block of the range,
+// which only the holder of each block's reverse delegation can write to. It returns the names
+// that are missing the token; the range is verified when there are none.
+func VerifyIPRange(cidr, token string) ([]string, error) {
+	names, err := RangeVerificationNames(cidr)
+	if err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, name := range names {
+		if ok, _ := VerifyDomain(name, token); !ok {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
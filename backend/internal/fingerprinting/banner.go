@@ -0,0 +1,165 @@
+package fingerprinting
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cortex-backend/internal/outbound"
+)
+
+//go:embed banner_signatures.json
+var defaultBannerSignatures []byte
+
+const (
+	bannerMaxBytes = 1024
+	bannerTimeout  = 3 * time.Second
+)
+
+// BannerSignature identifies a product from the banner a server sends on connect.
+// Product, Version and CPE may reference capture groups as $1, $2, ...
+type BannerSignature struct {
+	Service string `json:"service"`
+	Pattern string `json:"pattern"`
+	Product string `json:"product"`
+	Version string `json:"version,omitempty"`
+	CPE     string `json:"cpe,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Banner is a service identified from its banner
+type Banner struct {
+	Service string
+	Product string
+	Version string
+	CPE     string
+	Raw     string // Printable form of the bytes read, for evidence
+}
+
+var (
+	bannerSignatures     []*BannerSignature
+	bannerSignaturesOnce sync.Once
+)
+
+// LoadBannerSignatures parses a banner signature file
+func LoadBannerSignatures(path string) ([]*BannerSignature, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseBannerSignatures(data)
+}
+
+func parseBannerSignatures(data []byte) ([]*BannerSignature, error) {
+	var list []*BannerSignature
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid banner signature file: %v", err)
+	}
+	for _, sig := range list {
+		re, err := regexp.Compile(sig.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("banner signature %s %q: %v", sig.Service, sig.Pattern, err)
+		}
+		sig.re = re
+	}
+	return list, nil
+}
+
+// BannerSignatures returns the signatures from BANNER_SIGNATURES_PATH if set, otherwise the embedded defaults
+func BannerSignatures() []*BannerSignature {
+	bannerSignaturesOnce.Do(func() {
+		if path := os.Getenv("BANNER_SIGNATURES_PATH"); path != "" {
+			list, err := LoadBannerSignatures(path)
+			if err == nil {
+				bannerSignatures = list
+				return
+			}
+			log.Printf("[Scan] Failed to load banner signatures from %s: %v", path, err)
+		}
+		bannerSignatures, _ = parseBannerSignatures(defaultBannerSignatures)
+	})
+	return bannerSignatures
+}
+
+// GrabBanner reads what a server sends on connect, nudging silent servers with a blank
+// line, and matches it against the signature database. Returns nil if nothing was read.
+func GrabBanner(ctx context.Context, ip string, port int) *Banner {
+	conn, err := outbound.Dial(ctx, "tcp", net.JoinHostPort(ip, strconv.Itoa(port)), bannerTimeout)
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+
+	buf := make([]byte, bannerMaxBytes)
+	conn.SetReadDeadline(time.Now().Add(bannerTimeout))
+	n, _ := conn.Read(buf)
+	if n == 0 {
+		conn.SetDeadline(time.Now().Add(bannerTimeout))
+		if _, err := conn.Write([]byte("\r\n\r\n")); err != nil {
+			return nil
+		}
+		n, _ = conn.Read(buf)
+	}
+	if n == 0 {
+		return nil
+	}
+	return MatchBanner(buf[:n])
+}
+
+// MatchBanner identifies raw banner bytes using the first matching signature
+func MatchBanner(raw []byte) *Banner {
+	// Bytes map one-to-one onto runes so binary handshakes can be matched with \xNN escapes
+	text := latin1(raw)
+	banner := &Banner{Raw: printable(text)}
+	for _, sig := range BannerSignatures() {
+		m := sig.re.FindStringSubmatchIndex(text)
+		if m == nil {
+			continue
+		}
+		expand := func(template string) string {
+			return strings.TrimSpace(string(sig.re.ExpandString(nil, template, text, m)))
+		}
+		banner.Service = sig.Service
+		banner.Product = expand(sig.Product)
+		banner.Version = expand(sig.Version)
+		banner.CPE = strings.TrimSuffix(expand(sig.CPE), ":")
+		break
+	}
+	return banner
+}
+
+func latin1(raw []byte) string {
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// printable replaces control and binary bytes and truncates long banners
+func printable(text string) string {
+	out := strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == '\t' {
+			return ' '
+		}
+		if r < 0x20 || r > 0x7e {
+			return '.'
+		}
+		return r
+	}, text)
+	out = strings.TrimSpace(out)
+	if len(out) > 256 {
+		out = out[:256]
+	}
+	return out
+}
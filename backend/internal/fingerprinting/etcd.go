@@ -0,0 +1,94 @@
+package fingerprinting
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"cortex-backend/internal/outbound"
+)
+
+// probeEtcd reads /version and asks for a key count over the v3 JSON gateway.
+// A count_only range returns no keys or values, only whether reads are permitted.
+func probeEtcd(ctx context.Context, address string) (*ServiceInfo, error) {
+	client := &http.Client{
+		Timeout:   probeTimeout,
+		Transport: outbound.Transport(&tls.Config{InsecureSkipVerify: true}),
+	}
+
+	var lastErr error
+	for _, scheme := range []string{"https://", "http://"} {
+		base := scheme + address
+		var version struct {
+			Server  string `json:"etcdserver"`
+			Cluster string `json:"etcdcluster"`
+		}
+		if err := getJSON(ctx, client, base+"/version", &version); err != nil {
+			lastErr = err
+			continue
+		}
+		if version.Server == "" {
+			return nil, nil
+		}
+
+		info := &ServiceInfo{Service: "etcd", Version: version.Server}
+		status := "authentication required"
+		if keys, ok := etcdKeyCount(ctx, client, base); ok {
+			info.Unauthenticated = true
+			status = fmt.Sprintf("no authentication, %s keys readable", keys)
+		}
+		info.Detail = describe("etcd", info.Version, status)
+		return info, nil
+	}
+	return nil, lastErr
+}
+
+// etcdKeyCount counts every key from "\x00" upwards without reading any of them
+func etcdKeyCount(ctx context.Context, client *http.Client, base string) (string, bool) {
+	body := []byte(`{"key":"AA==","range_end":"AA==","count_only":true}`)
+	req, err := http.NewRequestWithContext(ctx, "POST", base+"/v3/kv/range", bytes.NewReader(body))
+	if err != nil {
+		return "", false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", false
+	}
+
+	var result struct {
+		Header *json.RawMessage `json:"header"`
+		Count  string           `json:"count"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result); err != nil || result.Header == nil {
+		return "", false
+	}
+	if result.Count == "" {
+		result.Count = "0" // proto3 JSON omits zero values
+	}
+	return result.Count, true
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(v)
+}
@@ -0,0 +1,124 @@
+package fingerprinting
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"math/bits"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const maxFaviconBytes = 256 * 1024
+
+var iconLinkRegex = regexp.MustCompile(`(?is)<link\b[^>]*\brel\s*=\s*["']?[^"'>]*\bicon\b[^>]*>`)
+var hrefRegex = regexp.MustCompile(`(?is)\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+
+// fetchFavicon downloads the page's icon (the <link rel="icon"> target, else /favicon.ico)
+// and returns its URL and Shodan-compatible mmh3 hash
+func fetchFavicon(ctx context.Context, client *http.Client, page *url.URL, body []byte) (string, int32) {
+	iconURL := page.ResolveReference(&url.URL{Path: "/favicon.ico"})
+	if href := iconHref(body); href != "" {
+		if ref, err := url.Parse(href); err == nil {
+			resolved := page.ResolveReference(ref)
+			// Icons served from another host (e.g. a CDN) or port say nothing about this service
+			if sameOrigin(resolved, page) && (resolved.Scheme == "http" || resolved.Scheme == "https") {
+				iconURL = resolved
+			}
+		}
+	}
+
+	resp, err := get(ctx, client, iconURL.String())
+	if err != nil {
+		return "", 0
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", 0
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFaviconBytes))
+	if err != nil || len(data) == 0 {
+		return "", 0
+	}
+	// A page instead of an icon means the server answers every path with the same document
+	if strings.HasPrefix(strings.ToLower(resp.Header.Get("Content-Type")), "text/html") {
+		return "", 0
+	}
+	return iconURL.String(), FaviconHash(data)
+}
+
+func iconHref(body []byte) string {
+	link := iconLinkRegex.Find(body)
+	if link == nil {
+		return ""
+	}
+	m := hrefRegex.FindSubmatch(link)
+	if m == nil {
+		return ""
+	}
+	for _, v := range m[1:] {
+		if len(v) > 0 {
+			return strings.TrimSpace(string(v))
+		}
+	}
+	return ""
+}
+
+// FaviconHash computes the hash Shodan's http.favicon.hash filter uses: the 32-bit
+// MurmurHash3 of the icon base64-encoded with a newline after every 76 characters
+func FaviconHash(data []byte) int32 {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var sb strings.Builder
+	for len(encoded) > 76 {
+		sb.WriteString(encoded[:76])
+		sb.WriteByte('\n')
+		encoded = encoded[76:]
+	}
+	sb.WriteString(encoded)
+	sb.WriteByte('\n')
+	return int32(murmur3([]byte(sb.String()), 0))
+}
+
+// murmur3 is the 32-bit x86 variant of MurmurHash3
+func murmur3(data []byte, seed uint32) uint32 {
+	const c1, c2 = 0xcc9e2d51, 0x1b873593
+	h := seed
+	n := len(data) / 4
+	for i := 0; i < n; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	tail := data[n*4:]
+	var k uint32
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
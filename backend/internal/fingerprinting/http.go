@@ -2,46 +2,275 @@ package fingerprinting
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"html"
 	"io"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
 	"time"
+
+	"cortex-backend/internal/outbound"
+)
+
+const (
+	httpTimeout   = 5 * time.Second
+	maxRedirects  = 5
+	maxBodyBytes  = 512 * 1024 // Enough to hash and scan a typical landing page
+	snippetBytes  = 1024
+	maxTitleChars = 256
 )
 
 type Fingerprint struct {
-	Server      string
-	PoweredBy   string
-	BodySnippet string
-	StatusCode  int
+	URL           string // Final URL after redirects
+	StatusCode    int
+	Server        string
+	PoweredBy     string
+	Headers       map[string]string // All response headers, repeated values joined with ", "
+	Title         string
+	FaviconHash   int32 // Shodan-compatible mmh3 hash, 0 when no favicon was found
+	FaviconURL    string
+	ContentHash   string // SHA-256 of the body (up to 512KB)
+	ContentLength int
+	BodySnippet   string // First 1KB of the body
+	Body          []byte `json:"-"` // Up to 512KB, for signature matching where the probe ran; not sent by agents
+	Redirects     []Redirect
+	TLS           *HTTPTLS // Nil for plain HTTP
+}
+
+// Redirect is one hop of the redirect chain
+type Redirect struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"statusCode"`
+	Location   string `json:"location"`
+	Followed   bool   `json:"followed"` // False when Location leaves the scanned host and port
+}
+
+// HTTPTLS describes the TLS session the response was served over
+type HTTPTLS struct {
+	Version     string    `json:"version"`
+	CipherSuite string    `json:"cipherSuite"`
+	ALPN        string    `json:"alpn,omitempty"`
+	Subject     string    `json:"subject,omitempty"`
+	Issuer      string    `json:"issuer,omitempty"`
+	NotAfter    time.Time `json:"notAfter"`
+	SelfSigned  bool      `json:"selfSigned"`
+}
+
+// Details is the part of a fingerprint stored with the service for later review
+type Details struct {
+	URL        string            `json:"url"`
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Redirects  []Redirect        `json:"redirects,omitempty"`
+	TLS        *HTTPTLS          `json:"tls,omitempty"`
+}
+
+// Details returns the response metadata that is not otherwise kept on the service
+func (f *Fingerprint) Details() Details {
+	return Details{
+		URL:        f.URL,
+		StatusCode: f.StatusCode,
+		Headers:    f.Headers,
+		Redirects:  f.Redirects,
+		TLS:        f.TLS,
+	}
 }
 
-// HTTPFingerprint basic HTTP response analysis
+// Text flattens the fingerprint for substring-based technology detection
+func (f *Fingerprint) Text() string {
+	parts := []string{f.Server, f.PoweredBy, f.Title, f.BodySnippet}
+	var text []string
+	for _, p := range parts {
+		if p != "" {
+			text = append(text, p)
+		}
+	}
+	return strings.Join(text, " ")
+}
+
+var (
+	titleRegex = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+	// Servers answering plain HTTP on a TLS port
+	httpsOnlyMarkers = []string{
+		"plain http request was sent to https port",
+		"client sent an http request to an https server",
+		"this combination of host and port requires tls",
+	}
+)
+
+// HTTPFingerprint fetches url and records headers, title, favicon hash, redirect chain and TLS session.
+// Certificates are not verified: the certificate itself is assessed by discovery.InspectTLS.
+// A plain http:// URL on a non-standard port is retried over HTTPS if the port turns out to speak TLS.
 func HTTPFingerprint(ctx context.Context, url string) (*Fingerprint, error) {
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: nil, // Add insecure if needed for internal dev
-		},
+	fp, err := fetchFingerprint(ctx, url)
+	if alt, ok := httpsAlternative(url); ok && (err != nil || fp.wantsTLS()) {
+		if tlsFP, tlsErr := fetchFingerprint(ctx, alt); tlsErr == nil {
+			return tlsFP, nil
+		}
 	}
+	return fp, err
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+func fetchFingerprint(ctx context.Context, rawURL string) (*Fingerprint, error) {
+	start, err := url.Parse(rawURL)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := client.Do(req)
+	var redirects []Redirect
+	client := &http.Client{
+		Timeout:   httpTimeout,
+		Transport: outbound.Transport(&tls.Config{InsecureSkipVerify: true}),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			prev := req.Response
+			follow := len(via) <= maxRedirects && sameOrigin(req.URL, start)
+			redirects = append(redirects, Redirect{
+				URL:        prev.Request.URL.String(),
+				StatusCode: prev.StatusCode,
+				Location:   prev.Header.Get("Location"),
+				Followed:   follow,
+			})
+			if !follow {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+
+	resp, err := get(ctx, client, rawURL)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024)) // Read first 1KB
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes))
 
+	sum := sha256.Sum256(body)
 	fp := &Fingerprint{
-		Server:      resp.Header.Get("Server"),
-		PoweredBy:   resp.Header.Get("X-Powered-By"),
-		BodySnippet: string(body),
-		StatusCode:  resp.StatusCode,
+		URL:           resp.Request.URL.String(),
+		StatusCode:    resp.StatusCode,
+		Server:        resp.Header.Get("Server"),
+		PoweredBy:     resp.Header.Get("X-Powered-By"),
+		Headers:       flattenHeaders(resp.Header),
+		Title:         extractTitle(body),
+		ContentHash:   hex.EncodeToString(sum[:]),
+		ContentLength: len(body),
+		BodySnippet:   string(body[:min(len(body), snippetBytes)]),
+		Body:          body,
+		Redirects:     redirects,
+		TLS:           describeTLS(resp.TLS),
 	}
-
+	// The favicon request must not add to the page's redirect chain
+	iconClient := *client
+	iconClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) > maxRedirects || !sameOrigin(req.URL, start) {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}
+	fp.FaviconURL, fp.FaviconHash = fetchFavicon(ctx, &iconClient, resp.Request.URL, body)
 	return fp, nil
 }
+
+func get(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Cortex/1.0)")
+	return client.Do(req)
+}
+
+// sameOrigin reports whether u is served by the scanned host and port. Redirects elsewhere are not followed:
+// another host may be out of scope, and another port is a different service that may be excluded.
+func sameOrigin(u, start *url.URL) bool {
+	return u.Hostname() == start.Hostname() && effectivePort(u) == effectivePort(start)
+}
+
+// effectivePort returns the port of u, or the default port of its scheme if none is given
+func effectivePort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if u.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// httpsAlternative returns the https:// form of a plain HTTP URL on a non-standard port
+func httpsAlternative(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "http" || u.Port() == "" || u.Port() == "80" {
+		return "", false
+	}
+	u.Scheme = "https"
+	return u.String(), true
+}
+
+// wantsTLS reports whether the server complained about plain HTTP on a TLS port
+func (f *Fingerprint) wantsTLS() bool {
+	if f.StatusCode != http.StatusBadRequest {
+		return false
+	}
+	body := strings.ToLower(f.BodySnippet)
+	for _, marker := range httpsOnlyMarkers {
+		if strings.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+func flattenHeaders(h http.Header) map[string]string {
+	headers := make(map[string]string, len(h))
+	for name, values := range h {
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+func extractTitle(body []byte) string {
+	m := titleRegex.FindSubmatch(body)
+	if m == nil {
+		return ""
+	}
+	title := strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+	// Cut on a character boundary so multi-byte titles stay valid UTF-8
+	if runes := []rune(title); len(runes) > maxTitleChars {
+		title = string(runes[:maxTitleChars])
+	}
+	return title
+}
+
+func describeTLS(state *tls.ConnectionState) *HTTPTLS {
+	if state == nil {
+		return nil
+	}
+	info := &HTTPTLS{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ALPN:        state.NegotiatedProtocol,
+	}
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		info.Subject = cert.Subject.CommonName
+		info.Issuer = cert.Issuer.CommonName
+		info.NotAfter = cert.NotAfter
+		info.SelfSigned = isSelfSigned(cert)
+	}
+	return info
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	if cert.Subject.String() != cert.Issuer.String() {
+		return false
+	}
+	return cert.CheckSignatureFrom(cert) == nil
+}
@@ -0,0 +1,221 @@
+package fingerprinting
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+)
+
+const (
+	mongoOpMsg      = 2013
+	mongoMaxMessage = 1 << 20
+	mongoCodeUnauth = 13 // Unauthorized
+)
+
+// probeMongoDB sends hello, buildInfo and a name-only listDatabases over OP_MSG.
+// Servers from 3.6 to 4.4.1 speak OP_MSG but reject hello, so a failed hello is retried as isMaster.
+// listDatabases succeeding means anyone can read the instance.
+func probeMongoDB(ctx context.Context, address string) (*ServiceInfo, error) {
+	conn, err := dialProbe(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	hello, err := mongoCommand(conn, 1, bsonDoc{{"hello", int32(1)}, {"$db", "admin"}})
+	if err != nil {
+		return nil, err
+	}
+	if bsonNumber(hello["ok"]) == 0 {
+		if hello, err = mongoCommand(conn, 2, bsonDoc{{"isMaster", int32(1)}, {"$db", "admin"}}); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := hello["maxWireVersion"]; !ok {
+		return nil, nil
+	}
+
+	info := &ServiceInfo{Service: "mongodb"}
+	if build, err := mongoCommand(conn, 3, bsonDoc{{"buildInfo", int32(1)}, {"$db", "admin"}}); err == nil {
+		info.Version, _ = build["version"].(string)
+	}
+
+	dbs, err := mongoCommand(conn, 4, bsonDoc{{"listDatabases", int32(1)}, {"nameOnly", true}, {"$db", "admin"}})
+	status := ""
+	if err == nil && bsonNumber(dbs["ok"]) == 1 {
+		info.Unauthenticated = true
+		status = "no authentication"
+	} else if err == nil && bsonNumber(dbs["code"]) == mongoCodeUnauth {
+		status = "authentication required"
+	}
+	info.Detail = describe("MongoDB", info.Version, status)
+	return info, nil
+}
+
+// mongoCommand writes one OP_MSG and decodes the body document of the reply
+func mongoCommand(conn net.Conn, requestID int32, cmd bsonDoc) (map[string]interface{}, error) {
+	body := cmd.encode()
+	msg := make([]byte, 0, 21+len(body))
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(21+len(body)))
+	msg = binary.LittleEndian.AppendUint32(msg, uint32(requestID))
+	msg = binary.LittleEndian.AppendUint32(msg, 0) // responseTo
+	msg = binary.LittleEndian.AppendUint32(msg, mongoOpMsg)
+	msg = binary.LittleEndian.AppendUint32(msg, 0) // flagBits
+	msg = append(msg, 0)                           // section kind 0: body
+	msg = append(msg, body...)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	length := binary.LittleEndian.Uint32(header[0:4])
+	if length < 21 || length > mongoMaxMessage || binary.LittleEndian.Uint32(header[12:16]) != mongoOpMsg {
+		return nil, errors.New("not a MongoDB OP_MSG reply")
+	}
+	payload := make([]byte, length-16)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		return nil, err
+	}
+	if payload[4] != 0 {
+		return nil, errors.New("unexpected OP_MSG section")
+	}
+	return decodeBSON(payload[5:])
+}
+
+// bsonDoc is an ordered BSON document; the command name must come first
+type bsonDoc []struct {
+	Key   string
+	Value interface{}
+}
+
+func (d bsonDoc) encode() []byte {
+	var buf bytes.Buffer
+	for _, e := range d {
+		switch v := e.Value.(type) {
+		case string:
+			buf.WriteByte(0x02)
+			buf.WriteString(e.Key)
+			buf.WriteByte(0)
+			binary.Write(&buf, binary.LittleEndian, int32(len(v)+1))
+			buf.WriteString(v)
+			buf.WriteByte(0)
+		case bool:
+			buf.WriteByte(0x08)
+			buf.WriteString(e.Key)
+			buf.WriteByte(0)
+			if v {
+				buf.WriteByte(1)
+			} else {
+				buf.WriteByte(0)
+			}
+		case int32:
+			buf.WriteByte(0x10)
+			buf.WriteString(e.Key)
+			buf.WriteByte(0)
+			binary.Write(&buf, binary.LittleEndian, v)
+		}
+	}
+	doc := binary.LittleEndian.AppendUint32(nil, uint32(buf.Len()+5))
+	doc = append(doc, buf.Bytes()...)
+	return append(doc, 0)
+}
+
+// decodeBSON reads the top-level scalar fields of a document; nested documents and arrays are skipped
+func decodeBSON(data []byte) (map[string]interface{}, error) {
+	if len(data) < 5 {
+		return nil, errors.New("short BSON document")
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	if size > len(data) || size < 5 {
+		return nil, errors.New("invalid BSON length")
+	}
+	data = data[4 : size-1]
+
+	fields := make(map[string]interface{})
+	for len(data) > 0 {
+		kind := data[0]
+		end := bytes.IndexByte(data[1:], 0)
+		if end < 0 {
+			return nil, errors.New("unterminated BSON key")
+		}
+		key := string(data[1 : 1+end])
+		data = data[2+end:]
+
+		var n int
+		switch kind {
+		case 0x01: // double
+			n = 8
+			if len(data) >= n {
+				fields[key] = math.Float64frombits(binary.LittleEndian.Uint64(data))
+			}
+		case 0x02: // string
+			if len(data) < 4 {
+				return nil, errors.New("truncated BSON string")
+			}
+			n = 4 + int(binary.LittleEndian.Uint32(data))
+			if n > 4 && len(data) >= n {
+				fields[key] = string(data[4 : n-1])
+			}
+		case 0x03, 0x04: // document, array
+			if len(data) < 4 {
+				return nil, errors.New("truncated BSON document")
+			}
+			n = int(binary.LittleEndian.Uint32(data))
+		case 0x05: // binary
+			if len(data) < 4 {
+				return nil, errors.New("truncated BSON binary")
+			}
+			n = 5 + int(binary.LittleEndian.Uint32(data))
+		case 0x07: // ObjectId
+			n = 12
+		case 0x08: // bool
+			n = 1
+			if len(data) >= n {
+				fields[key] = data[0] == 1
+			}
+		case 0x09, 0x11: // datetime, timestamp
+			n = 8
+		case 0x0A: // null
+			n = 0
+		case 0x10: // int32
+			n = 4
+			if len(data) >= n {
+				fields[key] = int32(binary.LittleEndian.Uint32(data))
+			}
+		case 0x12: // int64
+			n = 8
+			if len(data) >= n {
+				fields[key] = int64(binary.LittleEndian.Uint64(data))
+			}
+		case 0x13: // decimal128
+			n = 16
+		default:
+			return fields, nil // Nothing we need follows an unsupported type
+		}
+		if n < 0 || n > len(data) {
+			return nil, errors.New("truncated BSON value")
+		}
+		data = data[n:]
+	}
+	return fields, nil
+}
+
+// bsonNumber normalizes the numeric types servers use for fields such as ok and code
+func bsonNumber(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return 0
+}
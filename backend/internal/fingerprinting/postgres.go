@@ -0,0 +1,132 @@
+package fingerprinting
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+)
+
+const (
+	pgSSLRequestCode = 80877103
+	pgProtocol30     = 196608
+	pgAuthOK         = 0
+	pgMaxMessage     = 64 * 1024
+)
+
+// probePostgres sends an SSLRequest, then a startup message for the postgres user
+// without a password. The server either asks for credentials, rejects the login, or
+// (with trust authentication) lets us in; the session is terminated before any query.
+func probePostgres(ctx context.Context, address string) (*ServiceInfo, error) {
+	conn, err := dialProbe(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	sslRequest := binary.BigEndian.AppendUint32(nil, 8)
+	sslRequest = binary.BigEndian.AppendUint32(sslRequest, pgSSLRequestCode)
+	if _, err := conn.Write(sslRequest); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, 1)
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return nil, err
+	}
+
+	var stream net.Conn = conn
+	ssl := "no SSL"
+	switch answer[0] {
+	case 'S':
+		tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		stream = tlsConn
+		ssl = "SSL"
+	case 'N':
+	default:
+		return nil, nil
+	}
+
+	info := &ServiceInfo{Service: "postgresql"}
+	var startup []byte
+	startup = binary.BigEndian.AppendUint32(startup, 0) // Length, filled below
+	startup = binary.BigEndian.AppendUint32(startup, pgProtocol30)
+	for _, kv := range []string{"user", "postgres", "database", "postgres", "application_name", "cortex"} {
+		startup = append(append(startup, kv...), 0)
+	}
+	startup = append(startup, 0)
+	binary.BigEndian.PutUint32(startup, uint32(len(startup)))
+	if _, err := stream.Write(startup); err != nil {
+		info.Detail = describe("PostgreSQL", "", ssl)
+		return info, nil
+	}
+
+	reader := bufio.NewReader(stream)
+	status := "authentication required"
+	for {
+		kind, body, err := readPgMessage(reader)
+		if err != nil {
+			break
+		}
+		if kind == 'E' {
+			if msg := pgErrorMessage(body); msg != "" {
+				status = "login rejected: " + msg
+			}
+			break
+		}
+		if kind == 'R' && len(body) >= 4 {
+			if binary.BigEndian.Uint32(body) != pgAuthOK {
+				break // Password, SASL or certificate authentication requested
+			}
+			info.Unauthenticated = true
+			status = "no authentication"
+			continue
+		}
+		if kind == 'S' {
+			parts := strings.Split(string(body), "\x00")
+			if len(parts) >= 2 && parts[0] == "server_version" {
+				info.Version = strings.Fields(parts[1] + " ")[0]
+			}
+		}
+		if kind == 'Z' {
+			stream.Write([]byte{'X', 0, 0, 0, 4}) // Terminate
+			break
+		}
+	}
+
+	info.Detail = describe("PostgreSQL", info.Version, ssl+", "+status)
+	return info, nil
+}
+
+// readPgMessage reads one backend message: a type byte and a length-prefixed body
+func readPgMessage(reader *bufio.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if length < 4 || length > pgMaxMessage {
+		return 0, nil, errors.New("invalid PostgreSQL message length")
+	}
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return 0, nil, err
+	}
+	return header[0], body, nil
+}
+
+// pgErrorMessage extracts the human-readable M field of an ErrorResponse
+func pgErrorMessage(body []byte) string {
+	for _, field := range strings.Split(string(body), "\x00") {
+		if strings.HasPrefix(field, "M") {
+			return field[1:]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,91 @@
+package fingerprinting
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"cortex-backend/internal/outbound"
+)
+
+// ServiceInfo is what a protocol probe learned about a non-HTTP service
+type ServiceInfo struct {
+	Service         string // "redis", "mongodb", "postgresql", "etcd"
+	Product         string
+	Version         string // Empty when the service does not disclose it before authentication
+	CPE             string
+	Unauthenticated bool   // A read-only request succeeded without credentials
+	Detail          string // Short summary of the handshake, stored as the service fingerprint
+}
+
+// Probe is a safe, read-only handshake for one protocol. Run returns nil when the
+// port does not speak the protocol.
+type Probe struct {
+	Service string
+	Product string
+	CPE     string // Vendor and product part; the detected version is appended
+	Ports   []int
+	Run     func(ctx context.Context, address string) (*ServiceInfo, error)
+}
+
+// Probes are tried in order on the ports they are registered for
+var Probes = []Probe{
+	{Service: "redis", Product: "Redis", CPE: "cpe:/a:redis:redis", Ports: []int{6379}, Run: probeRedis},
+	{Service: "mongodb", Product: "MongoDB", CPE: "cpe:/a:mongodb:mongodb", Ports: []int{27017, 27018}, Run: probeMongoDB},
+	{Service: "postgresql", Product: "PostgreSQL", CPE: "cpe:/a:postgresql:postgresql", Ports: []int{5432}, Run: probePostgres},
+	{Service: "etcd", Product: "etcd", CPE: "cpe:/a:etcd:etcd", Ports: []int{2379}, Run: probeEtcd},
+}
+
+// probeTimeout bounds a whole probe, from dial to the last response
+const probeTimeout = 5 * time.Second
+
+// ProbeService runs the protocol probes registered for port and returns the first match
+func ProbeService(ctx context.Context, ip string, port int) *ServiceInfo {
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+	for _, probe := range Probes {
+		if !hasPort(probe.Ports, port) {
+			continue
+		}
+		info, err := probe.Run(ctx, address)
+		if err == nil && info != nil {
+			info.Product = probe.Product
+			info.CPE = probe.CPE
+			if info.Version != "" {
+				info.CPE += ":" + info.Version
+			}
+			return info
+		}
+	}
+	return nil
+}
+
+// dialProbe opens a TCP connection whose reads and writes share the probe deadline
+func dialProbe(ctx context.Context, address string) (net.Conn, error) {
+	conn, err := outbound.Dial(ctx, "tcp", address, probeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(probeTimeout))
+	return conn, nil
+}
+
+// describe formats a probe summary such as "Redis 7.2.4 (no authentication)"
+func describe(product, version, status string) string {
+	if version != "" {
+		product += " " + version
+	}
+	if status != "" {
+		product += " (" + status + ")"
+	}
+	return product
+}
+
+func hasPort(ports []int, port int) bool {
+	for _, p := range ports {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,71 @@
+package fingerprinting
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// probeRedis sends PING and, when no password is required, INFO server for the version
+func probeRedis(ctx context.Context, address string) (*ServiceInfo, error) {
+	conn, err := dialProbe(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(io.LimitReader(conn, 64*1024))
+
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		return nil, err
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSpace(line)
+
+	info := &ServiceInfo{Service: "redis"}
+	switch {
+	case line == "+PONG":
+		info.Unauthenticated = true
+	case strings.HasPrefix(line, "-NOAUTH"), strings.HasPrefix(line, "-WRONGPASS"),
+		strings.HasPrefix(line, "-ERR") && strings.Contains(strings.ToLower(line), "auth"):
+		info.Detail = describe("Redis", "", "authentication required")
+		return info, nil
+	case strings.HasPrefix(line, "-DENIED"):
+		info.Detail = describe("Redis", "", "protected mode, remote commands refused")
+		return info, nil
+	default:
+		return nil, nil
+	}
+
+	if _, err := conn.Write([]byte("INFO server\r\n")); err == nil {
+		info.Version = redisVersion(reader)
+	}
+	info.Detail = describe("Redis", info.Version, "no authentication")
+	return info, nil
+}
+
+// redisVersion reads a RESP bulk string reply to INFO and extracts redis_version
+func redisVersion(reader *bufio.Reader) string {
+	header, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(header, "$") {
+		return ""
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+	if err != nil || size <= 0 {
+		return ""
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(body), "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "redis_version:"); ok {
+			return v
+		}
+	}
+	return ""
+}
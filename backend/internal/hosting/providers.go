@@ -0,0 +1,141 @@
+package hosting
+
+import (
+	"bufio"
+	"embed"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed ranges/*.txt
+var embeddedRanges embed.FS
+
+// cdnDirective marks a range file as a CDN: reverse proxies whose edge IPs are shared by many unrelated customers
+const cdnDirective = "# cdn"
+
+// Provider is a CDN or hosting network identified by its published CIDR ranges
+type Provider struct {
+	Name     string
+	CDN      bool
+	Networks []*net.IPNet
+}
+
+// Database matches IP addresses against provider ranges
+type Database struct {
+	Providers []*Provider
+}
+
+// Load builds the provider database from the embedded CDN lists, then adds or replaces
+// providers with any <provider>.txt files found in PROVIDER_RANGES_DIR. Only Cloudflare and Fastly
+// are embedded; AWS, GCP, Azure and Akamai publish their ranges in formats that change too often
+// to vendor, so operators export them into that directory (e.g. aws.txt, cloudfront.txt, akamai.txt)
+// and add a "# cdn" line to the lists of CDN edge networks.
+func Load() *Database {
+	db := &Database{}
+
+	entries, _ := fs.ReadDir(embeddedRanges, "ranges")
+	for _, entry := range entries {
+		f, err := embeddedRanges.Open("ranges/" + entry.Name())
+		if err != nil {
+			continue
+		}
+		db.add(providerName(entry.Name()), f)
+		f.Close()
+	}
+
+	if dir := os.Getenv("PROVIDER_RANGES_DIR"); dir != "" {
+		if err := db.LoadDir(dir); err != nil {
+			log.Printf("[Hosting] Failed to load provider ranges from %s: %v", dir, err)
+		}
+	}
+	return db
+}
+
+// LoadDir reads every <provider>.txt CIDR list in dir, one range per line
+func (db *Database) LoadDir(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return err
+	}
+
+	for _, path := range files {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		db.add(providerName(path), f)
+		f.Close()
+	}
+	return nil
+}
+
+// Lookup returns the provider that owns ip, or nil if it is not in any known range. Published lists
+// overlap (CloudFront edges sit inside the AMAZON ranges), so the most specific network wins, and a CDN
+// wins a tie so shared edges are never treated as a single customer's host.
+func (db *Database) Lookup(ip string) *Provider {
+	parsed := net.ParseIP(ip)
+	if db == nil || parsed == nil {
+		return nil
+	}
+
+	var best *Provider
+	bestBits := -1
+	for _, p := range db.Providers {
+		for _, network := range p.Networks {
+			if !network.Contains(parsed) {
+				continue
+			}
+			bits, _ := network.Mask.Size()
+			if bits > bestBits || (bits == bestBits && p.CDN && !best.CDN) {
+				best, bestBits = p, bits
+			}
+		}
+	}
+	return best
+}
+
+// IsCDN reports whether ip belongs to a CDN edge network
+func (db *Database) IsCDN(ip string) bool {
+	p := db.Lookup(ip)
+	return p != nil && p.CDN
+}
+
+// add parses a CIDR list and registers it under name, replacing an existing provider of that name
+func (db *Database) add(name string, r io.Reader) {
+	provider := &Provider{Name: name}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.EqualFold(line, cdnDirective) {
+			provider.CDN = true
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		_, network, err := net.ParseCIDR(line)
+		if err != nil {
+			log.Printf("[Hosting] Ignoring invalid range %q for %s", line, name)
+			continue
+		}
+		provider.Networks = append(provider.Networks, network)
+	}
+
+	for i, existing := range db.Providers {
+		if existing.Name == name {
+			db.Providers[i] = provider
+			return
+		}
+	}
+	db.Providers = append(db.Providers, provider)
+}
+
+func providerName(path string) string {
+	return strings.ToLower(strings.TrimSuffix(filepath.Base(path), ".txt"))
+}
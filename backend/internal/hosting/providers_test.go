@@ -0,0 +1,58 @@
+package hosting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLookupOverlappingLists(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		// A cloud's full ranges with its CDN edges carved out of them, as AWS publishes AMAZON and CLOUDFRONT
+		"aws.txt":        "198.18.0.0/15\n2001:db8::/32\n",
+		"cloudfront.txt": "# cdn\n198.18.4.0/24\n2001:db8:4::/48\n",
+		// Same-size overlap: the CDN wins the tie
+		"colo.txt":  "203.0.113.0/24\n",
+		"edge.txt":  "# cdn\n203.0.113.0/24\n",
+		"empty.txt": "# only comments\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := &Database{}
+	if err := db.LoadDir(dir); err != nil {
+		t.Fatalf("LoadDir: %v", err)
+	}
+
+	tests := []struct {
+		ip       string
+		provider string
+		cdn      bool
+	}{
+		{"198.18.4.10", "cloudfront", true},
+		{"198.18.5.10", "aws", false},
+		{"198.19.255.1", "aws", false},
+		{"2001:db8:4::1", "cloudfront", true},
+		{"2001:db8:5::1", "aws", false},
+		{"203.0.113.7", "edge", true},
+		{"192.0.2.1", "", false},
+		{"not-an-ip", "", false},
+	}
+	for _, tt := range tests {
+		p := db.Lookup(tt.ip)
+		name := ""
+		if p != nil {
+			name = p.Name
+		}
+		if name != tt.provider {
+			t.Errorf("Lookup(%s) = %q, want %q", tt.ip, name, tt.provider)
+		}
+		if got := db.IsCDN(tt.ip); got != tt.cdn {
+			t.Errorf("IsCDN(%s) = %v, want %v", tt.ip, got, tt.cdn)
+		}
+	}
+}
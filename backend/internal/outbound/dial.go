@@ -0,0 +1,99 @@
+package outbound
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// transportDialTimeout bounds connection setup for HTTP clients; the client timeout bounds the rest
+const transportDialTimeout = 10 * time.Second
+
+// Dial connects to address through the Default dialer once the shared limiter allows it.
+// Hostnames are resolved first so every connection counts against the IP and network it
+// reaches; the addresses are tried in order. The connection holds its limiter slot until it is closed.
+func Dial(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, timeout)
+	ips, err := LookupHost(lookupCtx, host)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+
+	for _, ip := range ips {
+		var conn net.Conn
+		conn, err = dialLimited(ctx, network, net.JoinHostPort(ip, port), ip, timeout)
+		if err == nil || ctx.Err() != nil {
+			return conn, err
+		}
+	}
+	return nil, err
+}
+
+func dialLimited(ctx context.Context, network, address, ip string, timeout time.Duration) (net.Conn, error) {
+	release, err := Shared().Acquire(ctx, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := Default().DialContext(dialCtx, network, address)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &limitedConn{Conn: conn, release: release}, nil
+}
+
+// DialTLS connects through Dial and completes a TLS handshake within timeout
+func DialTLS(ctx context.Context, address string, timeout time.Duration, config *tls.Config) (*tls.Conn, error) {
+	conn, err := Dial(ctx, "tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// Transport returns an HTTP transport that dials through the shared limiter. Keep-alives are
+// disabled so idle pooled connections do not hold limiter slots.
+func Transport(tlsConfig *tls.Config) *http.Transport {
+	return &http.Transport{
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return Dial(ctx, network, address, transportDialTimeout)
+		},
+		TLSClientConfig:   tlsConfig,
+		DisableKeepAlives: true,
+	}
+}
+
+// limitedConn returns its limiter slot on Close
+type limitedConn struct {
+	net.Conn
+	release func()
+	once    sync.Once
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
@@ -0,0 +1,236 @@
+package outbound
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// Dialer opens connections to scan targets. Every probe dials through the process-wide
+// Dialer, so routing scans through a proxy or an egress pool is a configuration change.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+var (
+	defaultDialer     Dialer
+	defaultDialerOnce sync.Once
+)
+
+// Default returns the dialer configured by OUTBOUND_PROXY and OUTBOUND_SOURCE_IPS.
+// A misconfigured proxy is fatal rather than silently scanning from an unpublished address.
+func Default() Dialer {
+	defaultDialerOnce.Do(func() {
+		d, err := DialerFromEnv()
+		if err != nil {
+			log.Fatalf("[Scan] Invalid outbound network configuration: %v", err)
+		}
+		defaultDialer = d
+	})
+	return defaultDialer
+}
+
+// DialerFromEnv builds a dialer from the environment:
+//
+//	OUTBOUND_SOURCE_IPS  comma-separated local addresses to bind, used round-robin
+//	OUTBOUND_PROXY       socks5://[user:pass@]host:port or http://[user:pass@]host:port
+//
+// When both are set, connections to the proxy leave from the source addresses.
+func DialerFromEnv() (Dialer, error) {
+	var d Dialer = &net.Dialer{}
+	if list := os.Getenv("OUTBOUND_SOURCE_IPS"); list != "" {
+		pool, err := NewSourcePool(strings.Split(list, ","))
+		if err != nil {
+			return nil, err
+		}
+		d = pool
+	}
+	if raw := os.Getenv("OUTBOUND_PROXY"); raw != "" {
+		return NewProxyDialer(raw, d)
+	}
+	return d, nil
+}
+
+// SourcePool binds each connection to the next local address of the target's IP family
+type SourcePool struct {
+	v4, v6 []net.IP
+	next   atomic.Uint32
+}
+
+// NewSourcePool creates an egress pool from local IP addresses
+func NewSourcePool(addrs []string) (*SourcePool, error) {
+	p := &SourcePool{}
+	for _, a := range addrs {
+		ip := net.ParseIP(strings.TrimSpace(a))
+		if ip == nil {
+			return nil, fmt.Errorf("invalid source address %q", a)
+		}
+		if ip.To4() != nil {
+			p.v4 = append(p.v4, ip)
+		} else {
+			p.v6 = append(p.v6, ip)
+		}
+	}
+	if len(p.v4)+len(p.v6) == 0 {
+		return nil, fmt.Errorf("source address pool is empty")
+	}
+	return p, nil
+}
+
+// DialContext connects from a pool address. Targets of a family the pool has no address for are
+// refused, since their traffic would leave from an address customers have not allowlisted.
+func (p *SourcePool) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := p.v4
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		candidates = p.v6
+	} else if ip == nil && len(p.v4) == 0 {
+		candidates = p.v6 // Hostnames resolve to whichever family the bound address has
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no egress address configured for %s", host)
+	}
+
+	source := candidates[int(p.next.Add(1))%len(candidates)]
+	dialer := &net.Dialer{LocalAddr: &net.TCPAddr{IP: source}}
+	if strings.HasPrefix(network, "udp") {
+		dialer.LocalAddr = &net.UDPAddr{IP: source}
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+// Dial implements proxy.Dialer so the pool can carry connections to a SOCKS5 proxy
+func (p *SourcePool) Dial(network, address string) (net.Conn, error) {
+	return p.DialContext(context.Background(), network, address)
+}
+
+// NewProxyDialer routes connections through a SOCKS5 or HTTP CONNECT proxy reached via forward
+func NewProxyDialer(rawURL string, forward Dialer) (Dialer, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", rawURL)
+	}
+
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		var auth *proxy.Auth
+		if u.User != nil {
+			password, _ := u.User.Password()
+			auth = &proxy.Auth{User: u.User.Username(), Password: password}
+		}
+		d, err := proxy.SOCKS5("tcp", u.Host, auth, forwardDialer{forward})
+		if err != nil {
+			return nil, err
+		}
+		cd, ok := d.(proxy.ContextDialer)
+		if !ok {
+			return nil, fmt.Errorf("SOCKS5 dialer does not support contexts")
+		}
+		return cd, nil
+	case "http":
+		return &connectDialer{proxy: u, forward: forward}, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q (use socks5 or http)", u.Scheme)
+}
+
+// forwardDialer adapts a Dialer to the proxy package's interfaces
+type forwardDialer struct{ Dialer }
+
+func (f forwardDialer) Dial(network, address string) (net.Conn, error) {
+	return f.DialContext(context.Background(), network, address)
+}
+
+// connectDialer tunnels TCP connections through an HTTP proxy with CONNECT
+type connectDialer struct {
+	proxy   *url.URL
+	forward Dialer
+}
+
+func (d *connectDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if !strings.HasPrefix(network, "tcp") {
+		return nil, fmt.Errorf("HTTP proxy cannot carry %s", network)
+	}
+	conn, err := d.forward.DialContext(ctx, "tcp", d.proxy.Host)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if u := d.proxy.User; u != nil {
+		password, _ := u.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(u.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused CONNECT to %s: %s", address, resp.Status)
+	}
+	conn.SetDeadline(time.Time{})
+
+	// Servers that speak first (SSH, SMTP) may already have sent their banner
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn serves bytes read ahead during the CONNECT handshake before reading from the tunnel
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// PublishedAddresses lists the addresses scans originate from, for customers to allowlist.
+// OUTBOUND_PUBLISHED_IPS overrides the source pool, e.g. with the egress addresses of a proxy.
+func PublishedAddresses() []string {
+	list := os.Getenv("OUTBOUND_PUBLISHED_IPS")
+	if list == "" {
+		list = os.Getenv("OUTBOUND_SOURCE_IPS")
+	}
+	addrs := []string{}
+	for _, a := range strings.Split(list, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	return addrs
+}
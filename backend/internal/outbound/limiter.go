@@ -0,0 +1,165 @@
+package outbound
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limits caps how hard Cortex hits a single target. Rates count new connections
+// (each one a SYN) per second; connection caps count connections held open at once.
+type Limits struct {
+	PerIPRate       float64
+	PerNetworkRate  float64 // Shared by every address in the same /24 (IPv4) or /64 (IPv6)
+	PerIPConns      int
+	PerNetworkConns int
+}
+
+// DefaultLimits keep a full port scan of one host under a minute while staying polite to small networks
+var DefaultLimits = Limits{
+	PerIPRate:       50,
+	PerNetworkRate:  200,
+	PerIPConns:      20,
+	PerNetworkConns: 64,
+}
+
+// LimitsFromEnv reads OUTBOUND_PPS_PER_IP, OUTBOUND_PPS_PER_NETWORK, OUTBOUND_MAX_CONNS_PER_IP
+// and OUTBOUND_MAX_CONNS_PER_NETWORK, falling back to DefaultLimits
+func LimitsFromEnv() Limits {
+	limits := DefaultLimits
+	if v, err := strconv.ParseFloat(os.Getenv("OUTBOUND_PPS_PER_IP"), 64); err == nil && v > 0 {
+		limits.PerIPRate = v
+	}
+	if v, err := strconv.ParseFloat(os.Getenv("OUTBOUND_PPS_PER_NETWORK"), 64); err == nil && v > 0 {
+		limits.PerNetworkRate = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("OUTBOUND_MAX_CONNS_PER_IP")); err == nil && v > 0 {
+		limits.PerIPConns = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("OUTBOUND_MAX_CONNS_PER_NETWORK")); err == nil && v > 0 {
+		limits.PerNetworkConns = v
+	}
+	return limits
+}
+
+// Limiter enforces Limits per target address and per target network
+type Limiter struct {
+	limits    Limits
+	mu        sync.Mutex
+	targets   map[string]*target
+	lastPrune time.Time
+}
+
+type limitKey struct {
+	key   string
+	rps   float64
+	conns int
+}
+
+type target struct {
+	rate     *rate.Limiter
+	slots    chan struct{}
+	lastUsed time.Time
+}
+
+// idleTTL is how long an unused target keeps its state before it is pruned
+const idleTTL = 10 * time.Minute
+
+// NewLimiter creates a limiter enforcing limits
+func NewLimiter(limits Limits) *Limiter {
+	return &Limiter{
+		limits:    limits,
+		targets:   make(map[string]*target),
+		lastPrune: time.Now(),
+	}
+}
+
+var (
+	shared     *Limiter
+	sharedOnce sync.Once
+)
+
+// Shared returns the process-wide limiter used by every outbound probe
+func Shared() *Limiter {
+	sharedOnce.Do(func() {
+		shared = NewLimiter(LimitsFromEnv())
+	})
+	return shared
+}
+
+// Acquire waits until a new connection to host is allowed under both the per-address and
+// per-network limits. The returned release must be called once the connection is closed.
+func (l *Limiter) Acquire(ctx context.Context, host string) (func(), error) {
+	keys := []limitKey{{"ip:" + host, l.limits.PerIPRate, l.limits.PerIPConns}}
+	if network := networkOf(host); network != "" {
+		keys = append(keys, limitKey{"net:" + network, l.limits.PerNetworkRate, l.limits.PerNetworkConns})
+	}
+
+	// Slots are always taken address first, then network, so waiters cannot deadlock
+	var held []*target
+	release := func() {
+		for _, t := range held {
+			<-t.slots
+		}
+	}
+	for _, k := range keys {
+		t := l.target(k.key, k.rps, k.conns)
+		select {
+		case t.slots <- struct{}{}:
+			held = append(held, t)
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	for _, t := range held {
+		if err := t.rate.Wait(ctx); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+func (l *Limiter) target(key string, rps float64, conns int) *target {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastPrune) > idleTTL {
+		for k, t := range l.targets {
+			if now.Sub(t.lastUsed) > idleTTL && len(t.slots) == 0 {
+				delete(l.targets, k)
+			}
+		}
+		l.lastPrune = now
+	}
+
+	t, ok := l.targets[key]
+	if !ok {
+		t = &target{
+			rate:  rate.NewLimiter(rate.Limit(rps), 1),
+			slots: make(chan struct{}, conns),
+		}
+		l.targets[key] = t
+	}
+	t.lastUsed = now
+	return t
+}
+
+// networkOf returns the /24 (IPv4) or /64 (IPv6) containing host, or "" for hostnames
+func networkOf(host string) string {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
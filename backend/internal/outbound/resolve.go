@@ -0,0 +1,40 @@
+package outbound
+
+import (
+	"context"
+	"net"
+	"os"
+	"sync"
+)
+
+var (
+	resolver     *net.Resolver
+	resolverOnce sync.Once
+)
+
+// Resolver returns the resolver probes use for hostnames. Its queries travel over TCP through the
+// Default dialer, so they leave through the same proxy or egress addresses as the probes themselves.
+// OUTBOUND_RESOLVER (host:port) replaces the system nameserver, e.g. when the proxy cannot reach it.
+func Resolver() *net.Resolver {
+	resolverOnce.Do(func() {
+		server := os.Getenv("OUTBOUND_RESOLVER")
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				if server != "" {
+					address = server
+				}
+				return Default().DialContext(ctx, "tcp", address)
+			},
+		}
+	})
+	return resolver
+}
+
+// LookupHost resolves host through Resolver; IP literals are returned as they are
+func LookupHost(ctx context.Context, host string) ([]string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{host}, nil
+	}
+	return Resolver().LookupHost(ctx, host)
+}
@@ -0,0 +1,184 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"cortex-backend/pkg/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const agentColumns = `id, org_id, name, COALESCE(hostname, ''), COALESCE(version, ''), COALESCE(address, ''), last_seen_at, created_at`
+
+func scanAgent(row interface{ Scan(dest ...any) error }) (*models.Agent, error) {
+	var a models.Agent
+	err := row.Scan(&a.ID, &a.OrgID, &a.Name, &a.Hostname, &a.Version, &a.Address, &a.LastSeenAt, &a.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// CreateAgent registers an agent for an org; only the hash of its token is stored
+func (r *Repository) CreateAgent(ctx context.Context, orgID string, name string, tokenHash string) (*models.Agent, error) {
+	query := `INSERT INTO agents (org_id, name, token_hash) VALUES ($1, $2, $3) RETURNING ` + agentColumns
+	return scanAgent(r.DB.Pool.QueryRow(ctx, query, orgID, name, tokenHash))
+}
+
+// GetAgentByTokenHash finds the agent a token belongs to
+func (r *Repository) GetAgentByTokenHash(ctx context.Context, tokenHash string) (*models.Agent, error) {
+	query := `SELECT ` + agentColumns + ` FROM agents WHERE token_hash = $1`
+	return scanAgent(r.DB.Pool.QueryRow(ctx, query, tokenHash))
+}
+
+// GetAgentsByOrg lists the agents of an org
+func (r *Repository) GetAgentsByOrg(ctx context.Context, orgID string) ([]models.Agent, error) {
+	rows, err := r.DB.Pool.Query(ctx, `SELECT `+agentColumns+` FROM agents WHERE org_id = $1 ORDER BY created_at`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var agents []models.Agent
+	for rows.Next() {
+		a, err := scanAgent(rows)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, *a)
+	}
+	return agents, nil
+}
+
+// DeleteAgent removes an agent, revoking its token
+func (r *Repository) DeleteAgent(ctx context.Context, orgID string, agentID string) (bool, error) {
+	tag, err := r.DB.Pool.Exec(ctx, `DELETE FROM agents WHERE id = $1 AND org_id = $2`, agentID, orgID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// RecordAgentCheckIn stores what an agent reported about itself when registering
+func (r *Repository) RecordAgentCheckIn(ctx context.Context, agentID uuid.UUID, hostname, version, address string) error {
+	query := `UPDATE agents SET hostname = NULLIF($2, ''), version = NULLIF($3, ''), address = NULLIF($4, ''), last_seen_at = CURRENT_TIMESTAMP WHERE id = $1`
+	_, err := r.DB.Pool.Exec(ctx, query, agentID, hostname, version, address)
+	return err
+}
+
+// TouchAgent marks an agent as seen now
+func (r *Repository) TouchAgent(ctx context.Context, agentID uuid.UUID) error {
+	_, err := r.DB.Pool.Exec(ctx, `UPDATE agents SET last_seen_at = CURRENT_TIMESTAMP WHERE id = $1`, agentID)
+	return err
+}
+
+// CountOnlineAgents counts the agents of an org seen since the given time, and those still working on a
+// task claimed since busySince: an agent stops polling while all its slots are busy
+func (r *Repository) CountOnlineAgents(ctx context.Context, orgID uuid.UUID, since, busySince time.Time) (int, error) {
+	query := `
+		SELECT COUNT(*) FROM agents a 
+		WHERE a.org_id = $1 AND (a.last_seen_at >= $2 OR EXISTS (
+			SELECT 1 FROM agent_tasks t WHERE t.agent_id = a.id AND t.status = 'running' AND t.claimed_at >= $3
+		))`
+	var count int
+	err := r.DB.Pool.QueryRow(ctx, query, orgID, since, busySince).Scan(&count)
+	return count, err
+}
+
+// CountAgents counts the agents registered by an org, online or not
+func (r *Repository) CountAgents(ctx context.Context, orgID uuid.UUID) (int, error) {
+	var count int
+	err := r.DB.Pool.QueryRow(ctx, `SELECT COUNT(*) FROM agents WHERE org_id = $1`, orgID).Scan(&count)
+	return count, err
+}
+
+// CreateAgentTask queues a task for any agent of the org
+func (r *Repository) CreateAgentTask(ctx context.Context, orgID uuid.UUID, kind string, payload json.RawMessage) (uuid.UUID, error) {
+	var id uuid.UUID
+	err := r.DB.Pool.QueryRow(ctx, `INSERT INTO agent_tasks (org_id, kind, payload) VALUES ($1, $2, $3) RETURNING id`, orgID, kind, payload).Scan(&id)
+	return id, err
+}
+
+// RecordAgentFallback counts a task of the scan run that was run in-process after its agent task failed
+func (r *Repository) RecordAgentFallback(ctx context.Context, runID string) error {
+	_, err := r.DB.Pool.Exec(ctx, `UPDATE scan_runs SET agent_fallbacks = agent_fallbacks + 1 WHERE id = $1`, runID)
+	return err
+}
+
+// ClaimAgentTask hands the oldest pending task of the org to an agent, or returns nil if there is none.
+// SKIP LOCKED lets several agents poll at once without claiming the same task.
+func (r *Repository) ClaimAgentTask(ctx context.Context, orgID uuid.UUID, agentID uuid.UUID) (*models.AgentTask, error) {
+	query := `
+		UPDATE agent_tasks SET status = 'running', agent_id = $2, claimed_at = CURRENT_TIMESTAMP 
+		WHERE id = (
+			SELECT id FROM agent_tasks WHERE org_id = $1 AND status = 'pending' 
+			ORDER BY created_at LIMIT 1 FOR UPDATE SKIP LOCKED
+		) 
+		RETURNING id, kind, payload::text`
+	task := &models.AgentTask{OrgID: orgID, AgentID: &agentID, Status: "running"}
+	var payload string
+	err := r.DB.Pool.QueryRow(ctx, query, orgID, agentID).Scan(&task.ID, &task.Kind, &payload)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	task.Payload = json.RawMessage(payload)
+	return task, nil
+}
+
+// CompleteAgentTask stores the outcome of a task; only the agent that claimed it may complete it
+func (r *Repository) CompleteAgentTask(ctx context.Context, taskID uuid.UUID, agentID uuid.UUID, result json.RawMessage, taskErr string) (bool, error) {
+	status := "completed"
+	if taskErr != "" {
+		status = "failed"
+		result = nil
+	}
+	query := `
+		UPDATE agent_tasks SET status = $3, result = $4, error = NULLIF($5, ''), completed_at = CURRENT_TIMESTAMP 
+		WHERE id = $1 AND agent_id = $2 AND status = 'running'`
+	tag, err := r.DB.Pool.Exec(ctx, query, taskID, agentID, status, result, taskErr)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// GetAgentTask fetches a task with its result
+func (r *Repository) GetAgentTask(ctx context.Context, taskID uuid.UUID) (*models.AgentTask, error) {
+	query := `
+		SELECT id, org_id, agent_id, kind, payload::text, status, COALESCE(result::text, ''), COALESCE(error, ''), created_at, claimed_at, completed_at 
+		FROM agent_tasks WHERE id = $1`
+	var t models.AgentTask
+	var payload, result string
+	err := r.DB.Pool.QueryRow(ctx, query, taskID).Scan(&t.ID, &t.OrgID, &t.AgentID, &t.Kind, &payload, &t.Status, &result, &t.Error,
+		&t.CreatedAt, &t.ClaimedAt, &t.CompletedAt)
+	if err != nil {
+		return nil, err
+	}
+	t.Payload = json.RawMessage(payload)
+	if result != "" {
+		t.Result = json.RawMessage(result)
+	}
+	return &t, nil
+}
+
+// CancelAgentTask withdraws a task no agent has claimed yet, reporting whether it was still pending
+func (r *Repository) CancelAgentTask(ctx context.Context, taskID uuid.UUID) (bool, error) {
+	tag, err := r.DB.Pool.Exec(ctx, `UPDATE agent_tasks SET status = 'cancelled', completed_at = CURRENT_TIMESTAMP WHERE id = $1 AND status = 'pending'`, taskID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ExpireAgentTask gives up on a task that has not finished in time, whoever holds it
+func (r *Repository) ExpireAgentTask(ctx context.Context, taskID uuid.UUID, reason string) error {
+	query := `UPDATE agent_tasks SET status = 'failed', error = $2, completed_at = CURRENT_TIMESTAMP WHERE id = $1 AND status IN ('pending', 'running')`
+	_, err := r.DB.Pool.Exec(ctx, query, taskID, reason)
+	return err
+}
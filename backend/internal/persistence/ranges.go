@@ -0,0 +1,183 @@
+package persistence
+
+import (
+	"context"
+	"time"
+
+	"cortex-backend/pkg/models"
+	"github.com/google/uuid"
+)
+
+const ipRangeColumns = `id, org_id, cidr::text, COALESCE(asn, ''), verified, verification_token, verification_checked_at, verification_failed_at, scans_suspended, COALESCE(scan_profile, ''), created_at`
+
+func scanIPRange(row interface{ Scan(dest ...any) error }) (*models.IPRange, error) {
+	var ir models.IPRange
+	err := row.Scan(&ir.ID, &ir.OrgID, &ir.CIDR, &ir.ASN, &ir.Verified, &ir.VerificationToken, &ir.VerificationCheckedAt, &ir.VerificationFailedAt, &ir.ScansSuspended, &ir.ScanProfile, &ir.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &ir, nil
+}
+
+// CreateIPRange registers a network block for an org, returning the existing record if already registered
+func (r *Repository) CreateIPRange(ctx context.Context, orgID string, cidr string, asn string) (*models.IPRange, error) {
+	query := `
+		INSERT INTO ip_ranges (org_id, cidr, asn, verification_token) 
+		VALUES ($1, $2, NULLIF($3, ''), $4) 
+		ON CONFLICT (org_id, cidr) DO UPDATE SET asn = COALESCE(ip_ranges.asn, EXCLUDED.asn) 
+		RETURNING ` + ipRangeColumns
+	return scanIPRange(r.DB.Pool.QueryRow(ctx, query, orgID, cidr, asn, uuid.New().String()))
+}
+
+// GetIPRangeByCIDRAndOrg fetches a range registered by an org
+func (r *Repository) GetIPRangeByCIDRAndOrg(ctx context.Context, cidr string, orgID string) (*models.IPRange, error) {
+	query := `SELECT ` + ipRangeColumns + ` FROM ip_ranges WHERE cidr = $1 AND org_id = $2`
+	return scanIPRange(r.DB.Pool.QueryRow(ctx, query, cidr, orgID))
+}
+
+// GetIPRangeByID fetches a range by ID
+func (r *Repository) GetIPRangeByID(ctx context.Context, rangeID string) (*models.IPRange, error) {
+	query := `SELECT ` + ipRangeColumns + ` FROM ip_ranges WHERE id = $1`
+	return scanIPRange(r.DB.Pool.QueryRow(ctx, query, rangeID))
+}
+
+// GetIPRangesByOrg returns all ranges (verified and unverified) registered by an org
+func (r *Repository) GetIPRangesByOrg(ctx context.Context, orgID string) ([]models.IPRange, error) {
+	return r.queryIPRanges(ctx, `SELECT `+ipRangeColumns+` FROM ip_ranges WHERE org_id = $1 ORDER BY created_at DESC`, orgID)
+}
+
+// IPRangeClaimedByOtherOrg reports whether a range overlapping cidr has been verified by a different org
+func (r *Repository) IPRangeClaimedByOtherOrg(ctx context.Context, cidr string, orgID string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM ip_ranges WHERE verified = true AND org_id <> $2 AND cidr && $1::cidr)`
+	var claimed bool
+	err := r.DB.Pool.QueryRow(ctx, query, cidr, orgID).Scan(&claimed)
+	return claimed, err
+}
+
+// MarkIPRangeReverified records a successful periodic ownership check, ending any grace period
+func (r *Repository) MarkIPRangeReverified(ctx context.Context, rangeID string) error {
+	query := `UPDATE ip_ranges SET verification_checked_at = CURRENT_TIMESTAMP, verification_failed_at = NULL, verification_warned_at = NULL WHERE id = $1`
+	_, err := r.DB.Pool.Exec(ctx, query, rangeID)
+	return err
+}
+
+// MarkIPRangeReverifyFailed records a failed periodic ownership check and returns when the failures
+// started and when the owner was last warned, if ever
+func (r *Repository) MarkIPRangeReverifyFailed(ctx context.Context, rangeID string) (time.Time, *time.Time, error) {
+	var failedSince time.Time
+	var warnedAt *time.Time
+	query := `
+		UPDATE ip_ranges SET verification_checked_at = CURRENT_TIMESTAMP, verification_failed_at = COALESCE(verification_failed_at, CURRENT_TIMESTAMP) 
+		WHERE id = $1 
+		RETURNING verification_failed_at, verification_warned_at`
+	err := r.DB.Pool.QueryRow(ctx, query, rangeID).Scan(&failedSince, &warnedAt)
+	return failedSince, warnedAt, err
+}
+
+// MarkIPRangeWarned records that the owner of a range was warned about failed re-verification
+func (r *Repository) MarkIPRangeWarned(ctx context.Context, rangeID string) error {
+	_, err := r.DB.Pool.Exec(ctx, `UPDATE ip_ranges SET verification_warned_at = CURRENT_TIMESTAMP WHERE id = $1`, rangeID)
+	return err
+}
+
+// SuspendIPRange revokes verification of a range whose ownership could not be reconfirmed, stopping scheduled scans
+func (r *Repository) SuspendIPRange(ctx context.Context, rangeID string) error {
+	query := `UPDATE ip_ranges SET verified = false, scans_suspended = true WHERE id = $1`
+	_, err := r.DB.Pool.Exec(ctx, query, rangeID)
+	return err
+}
+
+// GetAllVerifiedIPRanges returns verified ranges across all organizations (for scheduler)
+func (r *Repository) GetAllVerifiedIPRanges(ctx context.Context) ([]models.IPRange, error) {
+	return r.queryIPRanges(ctx, `SELECT `+ipRangeColumns+` FROM ip_ranges WHERE verified = true`)
+}
+
+func (r *Repository) queryIPRanges(ctx context.Context, query string, args ...any) ([]models.IPRange, error) {
+	rows, err := r.DB.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ranges []models.IPRange
+	for rows.Next() {
+		ir, err := scanIPRange(rows)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, *ir)
+	}
+	return ranges, nil
+}
+
+// UpdateIPRangeVerification updates the verification status of a range.
+// A successful verification also lifts any re-verification failure or suspension.
+func (r *Repository) UpdateIPRangeVerification(ctx context.Context, rangeID string, verified bool) error {
+	query := `
+		UPDATE ip_ranges SET verified = $1, verification_checked_at = CURRENT_TIMESTAMP, 
+			verification_failed_at = NULL, verification_warned_at = NULL, scans_suspended = NOT $1 AND scans_suspended 
+		WHERE id = $2`
+	_, err := r.DB.Pool.Exec(ctx, query, verified, rangeID)
+	return err
+}
+
+// UpdateIPRangeScanProfile selects the port scan profile used for a range
+func (r *Repository) UpdateIPRangeScanProfile(ctx context.Context, rangeID string, profile string) error {
+	_, err := r.DB.Pool.Exec(ctx, `UPDATE ip_ranges SET scan_profile = $1 WHERE id = $2`, profile, rangeID)
+	return err
+}
+
+// CreateRangeScanRun initializes a new scan record for an IP range
+func (r *Repository) CreateRangeScanRun(ctx context.Context, rangeID string, scanProfile string) (string, error) {
+	id := uuid.New().String()
+	query := `INSERT INTO scan_runs (id, range_id, status, scan_profile) VALUES ($1, $2, 'running', $3)`
+	_, err := r.DB.Pool.Exec(ctx, query, id, rangeID, scanProfile)
+	return id, err
+}
+
+// GetAssetsByRange retrieves all live hosts found in an IP range
+func (r *Repository) GetAssetsByRange(ctx context.Context, rangeID string) ([]models.Asset, error) {
+	query := `SELECT id, range_id, COALESCE(subdomain, ''), host(ip_address), family(ip_address), COALESCE(ptr, ''), COALESCE(provider, ''), cdn, last_seen FROM assets WHERE range_id = $1 ORDER BY ip_address`
+	rows, err := r.DB.Pool.Query(ctx, query, rangeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assets []models.Asset
+	for rows.Next() {
+		var a models.Asset
+		err := rows.Scan(&a.ID, &a.RangeID, &a.Subdomain, &a.IPAddress, &a.IPVersion, &a.PTR, &a.Provider, &a.CDN, &a.LastSeen)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, a)
+	}
+	return assets, nil
+}
+
+// GetLatestFindingsForRange retrieves findings attributed to an IP range
+func (r *Repository) GetLatestFindingsForRange(ctx context.Context, rangeID string) ([]models.Finding, error) {
+	query := `
+		SELECT id, service_id, domain_id, range_id, COALESCE(target, ''), type, severity, description, remediation, COALESCE(evidence, '{}'), first_seen, last_seen
+		FROM findings
+		WHERE range_id = $1
+		ORDER BY last_seen DESC
+	`
+	rows, err := r.DB.Pool.Query(ctx, query, rangeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var findings []models.Finding
+	for rows.Next() {
+		var f models.Finding
+		err := rows.Scan(&f.ID, &f.ServiceID, &f.DomainID, &f.RangeID, &f.Target, &f.Type, &f.Severity, &f.Description, &f.Remediation, &f.Evidence, &f.FirstSeen, &f.LastSeen)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, f)
+	}
+	return findings, nil
+}
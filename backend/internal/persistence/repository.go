@@ -2,6 +2,7 @@ package persistence
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -18,10 +19,10 @@ func NewRepository(database *db.Database) *Repository {
 }
 
 // CreateScanRun initializes a new scan record
-func (r *Repository) CreateScanRun(ctx context.Context, domainID string) (string, error) {
+func (r *Repository) CreateScanRun(ctx context.Context, domainID string, scanProfile string) (string, error) {
 	id := uuid.New().String()
-	query := `INSERT INTO scan_runs (id, domain_id, status) VALUES ($1, $2, 'running')`
-	_, err := r.DB.Pool.Exec(ctx, query, id, domainID)
+	query := `INSERT INTO scan_runs (id, domain_id, status, scan_profile) VALUES ($1, $2, 'running', $3)`
+	_, err := r.DB.Pool.Exec(ctx, query, id, domainID, scanProfile)
 	return id, err
 }
 
@@ -46,57 +47,154 @@ func (r *Repository) GetOrCreateDomain(ctx context.Context, orgID string, domain
 	return id, err
 }
 
-// SaveAsset saves or updates an asset (domain/IP)
+// SaveAsset saves or updates an asset (domain/IP, or IP within a registered range)
 func (r *Repository) SaveAsset(ctx context.Context, asset *models.Asset) error {
+	if asset.RangeID != nil {
+		return r.saveRangeAsset(ctx, asset)
+	}
+
 	query := `
-		INSERT INTO assets (id, domain_id, subdomain, ip_address) 
-		VALUES ($1, $2, $3, $4) 
+		INSERT INTO assets (id, domain_id, subdomain, ip_address, ptr, provider, cdn) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7) 
 		ON CONFLICT (domain_id, subdomain, ip_address) 
-		DO UPDATE SET last_seen = CURRENT_TIMESTAMP 
+		DO UPDATE SET last_seen = CURRENT_TIMESTAMP, ptr = $5, provider = $6, cdn = $7 
 		RETURNING id`
 	if asset.ID == uuid.Nil {
 		asset.ID = uuid.New()
 	}
-	err := r.DB.Pool.QueryRow(ctx, query, asset.ID, asset.DomainID, asset.Subdomain, asset.IPAddress).Scan(&asset.ID)
+	err := r.DB.Pool.QueryRow(ctx, query, asset.ID, asset.DomainID, asset.Subdomain, asset.IPAddress, asset.PTR, asset.Provider, asset.CDN).Scan(&asset.ID)
+	return err
+}
+
+func (r *Repository) saveRangeAsset(ctx context.Context, asset *models.Asset) error {
+	query := `
+		INSERT INTO assets (id, range_id, subdomain, ip_address, ptr, provider, cdn) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7) 
+		ON CONFLICT (range_id, ip_address) WHERE range_id IS NOT NULL 
+		DO UPDATE SET last_seen = CURRENT_TIMESTAMP, subdomain = $3, ptr = $5, provider = $6, cdn = $7 
+		RETURNING id`
+	if asset.ID == uuid.Nil {
+		asset.ID = uuid.New()
+	}
+	err := r.DB.Pool.QueryRow(ctx, query, asset.ID, asset.RangeID, asset.Subdomain, asset.IPAddress, asset.PTR, asset.Provider, asset.CDN).Scan(&asset.ID)
 	return err
 }
 
 // SaveService saves or updates a discovered service
 func (r *Repository) SaveService(ctx context.Context, service *models.Service) error {
 	query := `
-		INSERT INTO services (id, asset_id, port, protocol, fingerprint, technology) 
-		VALUES ($1, $2, $3, $4, $5, $6) 
+		INSERT INTO services (id, asset_id, port, protocol, fingerprint, technology, tls_grade, tls_versions, tls_weak_ciphers, version, unauthenticated, product, cpe, http_title, favicon_hash, technologies, content_hash, http_details) 
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, NULLIF($10, ''), $11, NULLIF($12, ''), NULLIF($13, ''), NULLIF($14, ''), NULLIF($15, 0), $16, NULLIF($17, ''), $18) 
 		ON CONFLICT (asset_id, port, protocol) 
-		DO UPDATE SET last_seen = CURRENT_TIMESTAMP, fingerprint = $5, technology = $6 
+		DO UPDATE SET last_seen = CURRENT_TIMESTAMP, fingerprint = $5, technology = $6, tls_grade = NULLIF($7, ''), tls_versions = $8, tls_weak_ciphers = $9, 
+			version = NULLIF($10, ''), unauthenticated = $11, product = NULLIF($12, ''), cpe = NULLIF($13, ''), 
+			http_title = NULLIF($14, ''), favicon_hash = NULLIF($15, 0), technologies = $16, content_hash = NULLIF($17, ''), http_details = $18 
 		RETURNING id`
 	if service.ID == uuid.Nil {
 		service.ID = uuid.New()
 	}
-	err := r.DB.Pool.QueryRow(ctx, query, service.ID, service.AssetID, service.Port, service.Protocol, service.Fingerprint, service.Technology).Scan(&service.ID)
+	err := r.DB.Pool.QueryRow(ctx, query, service.ID, service.AssetID, service.Port, service.Protocol, service.Fingerprint, service.Technology,
+		service.TLSGrade, service.TLSVersions, service.WeakCiphers, service.Version, service.Unauthenticated, service.Product, service.CPE,
+		service.HTTPTitle, service.FaviconHash, service.Technologies, service.ContentHash, service.HTTPDetails).Scan(&service.ID)
+	return err
+}
+
+// SaveCertificate records the certificate currently presented by a service
+func (r *Repository) SaveCertificate(ctx context.Context, cert *models.Certificate) error {
+	query := `
+		INSERT INTO certificates (id, service_id, subject, issuer, not_before, not_after, key_type, key_bits, signature_algorithm, sans, self_signed, chain_complete, chain_error, fingerprint_sha256) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NULLIF($13, ''), $14) 
+		ON CONFLICT (service_id) 
+		DO UPDATE SET last_seen = CURRENT_TIMESTAMP, subject = $3, issuer = $4, not_before = $5, not_after = $6, key_type = $7, key_bits = $8, 
+			signature_algorithm = $9, sans = $10, self_signed = $11, chain_complete = $12, chain_error = NULLIF($13, ''), fingerprint_sha256 = $14 
+		RETURNING id`
+	if cert.ID == uuid.Nil {
+		cert.ID = uuid.New()
+	}
+	err := r.DB.Pool.QueryRow(ctx, query, cert.ID, cert.ServiceID, cert.Subject, cert.Issuer, cert.NotBefore, cert.NotAfter, cert.KeyType, cert.KeyBits,
+		cert.SignatureAlgorithm, cert.SANs, cert.SelfSigned, cert.ChainComplete, cert.ChainError, cert.FingerprintSHA256).Scan(&cert.ID)
+	return err
+}
+
+// GetCertificatesByDomain retrieves the certificates presented by services of a domain, soonest to expire first
+func (r *Repository) GetCertificatesByDomain(ctx context.Context, domainID string) ([]models.Certificate, error) {
+	query := `
+		SELECT c.id, c.service_id, COALESCE(NULLIF(a.subdomain, ''), host(a.ip_address)), s.port, COALESCE(c.subject, ''), COALESCE(c.issuer, ''), 
+			c.not_before, c.not_after, COALESCE(c.key_type, ''), COALESCE(c.key_bits, 0), COALESCE(c.signature_algorithm, ''), COALESCE(c.sans, '{}'), 
+			c.self_signed, c.chain_complete, COALESCE(c.chain_error, ''), COALESCE(c.fingerprint_sha256, ''), c.last_seen
+		FROM certificates c
+		JOIN services s ON c.service_id = s.id
+		JOIN assets a ON s.asset_id = a.id
+		WHERE a.domain_id = $1
+		ORDER BY c.not_after ASC
+	`
+	rows, err := r.DB.Pool.Query(ctx, query, domainID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var certs []models.Certificate
+	for rows.Next() {
+		var c models.Certificate
+		err := rows.Scan(&c.ID, &c.ServiceID, &c.Host, &c.Port, &c.Subject, &c.Issuer, &c.NotBefore, &c.NotAfter, &c.KeyType, &c.KeyBits,
+			&c.SignatureAlgorithm, &c.SANs, &c.SelfSigned, &c.ChainComplete, &c.ChainError, &c.FingerprintSHA256, &c.LastSeen)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, c)
+	}
+	return certs, nil
+}
+
+// GetPassiveCache fetches the cached Certificate Transparency names of a root domain
+func (r *Repository) GetPassiveCache(ctx context.Context, rootDomain string) (*models.PassiveCache, error) {
+	var c models.PassiveCache
+	query := `SELECT root_domain, subdomains, COALESCE(ct_cursor, ''), fetched_at FROM passive_discovery_cache WHERE root_domain = $1`
+	err := r.DB.Pool.QueryRow(ctx, query, rootDomain).Scan(&c.RootDomain, &c.Subdomains, &c.CTCursor, &c.FetchedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SavePassiveCache merges newly seen names into the cache of a root domain and advances its CT cursor
+func (r *Repository) SavePassiveCache(ctx context.Context, rootDomain string, subdomains []string, cursor string) error {
+	if subdomains == nil {
+		subdomains = []string{}
+	}
+	query := `
+		INSERT INTO passive_discovery_cache (root_domain, subdomains, ct_cursor, fetched_at) 
+		VALUES ($1, $2, NULLIF($3, ''), CURRENT_TIMESTAMP) 
+		ON CONFLICT (root_domain) 
+		DO UPDATE SET subdomains = ARRAY(SELECT DISTINCT unnest(passive_discovery_cache.subdomains || EXCLUDED.subdomains)), 
+			ct_cursor = COALESCE(EXCLUDED.ct_cursor, passive_discovery_cache.ct_cursor), fetched_at = CURRENT_TIMESTAMP`
+	_, err := r.DB.Pool.Exec(ctx, query, rootDomain, subdomains, cursor)
 	return err
 }
 
 // SaveFinding saves a discovered risk
 func (r *Repository) SaveFinding(ctx context.Context, finding *models.Finding) error {
 	query := `
-		INSERT INTO findings (id, service_id, type, severity, description, remediation) 
-		VALUES ($1, $2, $3, $4, $5, $6) 
+		INSERT INTO findings (id, service_id, domain_id, range_id, target, type, severity, description, remediation, evidence) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) 
 		RETURNING id`
 	if finding.ID == uuid.Nil {
 		finding.ID = uuid.New()
 	}
-	err := r.DB.Pool.QueryRow(ctx, query, finding.ID, finding.ServiceID, finding.Type, finding.Severity, finding.Description, finding.Remediation).Scan(&finding.ID)
+	err := r.DB.Pool.QueryRow(ctx, query, finding.ID, finding.ServiceID, finding.DomainID, finding.RangeID, finding.Target, finding.Type, finding.Severity, finding.Description, finding.Remediation,
+		finding.Evidence).Scan(&finding.ID)
 	return err
 }
 
 // GetLatestFindingsForDomain retrieves findings from the latest successful scan run
 func (r *Repository) GetLatestFindingsForDomain(ctx context.Context, domainID string) ([]models.Finding, error) {
 	query := `
-		SELECT f.id, f.service_id, f.type, f.severity, f.description, f.remediation, f.first_seen, f.last_seen
+		SELECT f.id, f.service_id, f.domain_id, COALESCE(f.target, ''), f.type, f.severity, f.description, f.remediation, COALESCE(f.evidence, '{}'), f.first_seen, f.last_seen
 		FROM findings f
-		JOIN services s ON f.service_id = s.id
-		JOIN assets a ON s.asset_id = a.id
-		WHERE a.domain_id = $1
+		LEFT JOIN services s ON f.service_id = s.id
+		LEFT JOIN assets a ON s.asset_id = a.id
+		WHERE COALESCE(f.domain_id, a.domain_id) = $1
 		ORDER BY f.last_seen DESC
 	`
 	rows, err := r.DB.Pool.Query(ctx, query, domainID)
@@ -108,7 +206,7 @@ func (r *Repository) GetLatestFindingsForDomain(ctx context.Context, domainID st
 	var findings []models.Finding
 	for rows.Next() {
 		var f models.Finding
-		err := rows.Scan(&f.ID, &f.ServiceID, &f.Type, &f.Severity, &f.Description, &f.Remediation, &f.FirstSeen, &f.LastSeen)
+		err := rows.Scan(&f.ID, &f.ServiceID, &f.DomainID, &f.Target, &f.Type, &f.Severity, &f.Description, &f.Remediation, &f.Evidence, &f.FirstSeen, &f.LastSeen)
 		if err != nil {
 			return nil, err
 		}
@@ -119,7 +217,7 @@ func (r *Repository) GetLatestFindingsForDomain(ctx context.Context, domainID st
 
 // GetVerifiedDomains returns all domains that have been successfully verified for a specific org
 func (r *Repository) GetVerifiedDomains(ctx context.Context, orgID string) ([]models.Domain, error) {
-	query := `SELECT id, org_id, root_domain, verified, verification_token, created_at FROM domains WHERE verified = true AND org_id = $1`
+	query := `SELECT id, org_id, root_domain, verified, verification_token, COALESCE(verification_method, ''), verification_checked_at, verification_failed_at, scans_suspended, COALESCE(scan_profile, ''), created_at FROM domains WHERE verified = true AND org_id = $1`
 	rows, err := r.DB.Pool.Query(ctx, query, orgID)
 	if err != nil {
 		return nil, err
@@ -129,7 +227,7 @@ func (r *Repository) GetVerifiedDomains(ctx context.Context, orgID string) ([]mo
 	var domains []models.Domain
 	for rows.Next() {
 		var d models.Domain
-		err := rows.Scan(&d.ID, &d.OrgID, &d.RootDomain, &d.Verified, &d.VerificationToken, &d.CreatedAt)
+		err := rows.Scan(&d.ID, &d.OrgID, &d.RootDomain, &d.Verified, &d.VerificationToken, &d.VerificationMethod, &d.VerificationCheckedAt, &d.VerificationFailedAt, &d.ScansSuspended, &d.ScanProfile, &d.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -140,7 +238,7 @@ func (r *Repository) GetVerifiedDomains(ctx context.Context, orgID string) ([]mo
 
 // GetAllDomainsByOrg returns all domains (verified and unverified) for a specific org
 func (r *Repository) GetAllDomainsByOrg(ctx context.Context, orgID string) ([]models.Domain, error) {
-	query := `SELECT id, org_id, root_domain, verified, verification_token, created_at FROM domains WHERE org_id = $1 ORDER BY created_at DESC`
+	query := `SELECT id, org_id, root_domain, verified, verification_token, COALESCE(verification_method, ''), verification_checked_at, verification_failed_at, scans_suspended, COALESCE(scan_profile, ''), created_at FROM domains WHERE org_id = $1 ORDER BY created_at DESC`
 	rows, err := r.DB.Pool.Query(ctx, query, orgID)
 	if err != nil {
 		return nil, err
@@ -150,7 +248,7 @@ func (r *Repository) GetAllDomainsByOrg(ctx context.Context, orgID string) ([]mo
 	var domains []models.Domain
 	for rows.Next() {
 		var d models.Domain
-		err := rows.Scan(&d.ID, &d.OrgID, &d.RootDomain, &d.Verified, &d.VerificationToken, &d.CreatedAt)
+		err := rows.Scan(&d.ID, &d.OrgID, &d.RootDomain, &d.Verified, &d.VerificationToken, &d.VerificationMethod, &d.VerificationCheckedAt, &d.VerificationFailedAt, &d.ScansSuspended, &d.ScanProfile, &d.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -161,7 +259,7 @@ func (r *Repository) GetAllDomainsByOrg(ctx context.Context, orgID string) ([]mo
 
 // GetAllVerifiedDomains returns all verified domains across all organizations (for scheduler)
 func (r *Repository) GetAllVerifiedDomains(ctx context.Context) ([]models.Domain, error) {
-	query := `SELECT id, org_id, root_domain, verified, verification_token, created_at FROM domains WHERE verified = true`
+	query := `SELECT id, org_id, root_domain, verified, verification_token, COALESCE(verification_method, ''), verification_checked_at, verification_failed_at, scans_suspended, COALESCE(scan_profile, ''), created_at FROM domains WHERE verified = true`
 	rows, err := r.DB.Pool.Query(ctx, query)
 	if err != nil {
 		return nil, err
@@ -171,7 +269,7 @@ func (r *Repository) GetAllVerifiedDomains(ctx context.Context) ([]models.Domain
 	var domains []models.Domain
 	for rows.Next() {
 		var d models.Domain
-		err := rows.Scan(&d.ID, &d.OrgID, &d.RootDomain, &d.Verified, &d.VerificationToken, &d.CreatedAt)
+		err := rows.Scan(&d.ID, &d.OrgID, &d.RootDomain, &d.Verified, &d.VerificationToken, &d.VerificationMethod, &d.VerificationCheckedAt, &d.VerificationFailedAt, &d.ScansSuspended, &d.ScanProfile, &d.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -180,24 +278,112 @@ func (r *Repository) GetAllVerifiedDomains(ctx context.Context) ([]models.Domain
 	return domains, nil
 }
 
-// UpdateDomainVerification updates the verification status of a domain
-func (r *Repository) UpdateDomainVerification(ctx context.Context, domainID string, verified bool) error {
-	query := `UPDATE domains SET verified = $1 WHERE id = $2`
-	_, err := r.DB.Pool.Exec(ctx, query, verified, domainID)
+// UpdateDomainVerification updates the verification status of a domain and the method that proved ownership.
+// A successful verification also lifts any re-verification failure or suspension.
+func (r *Repository) UpdateDomainVerification(ctx context.Context, domainID string, verified bool, method string) error {
+	query := `
+		UPDATE domains SET verified = $1, verification_method = NULLIF($2, ''), verification_checked_at = CURRENT_TIMESTAMP, 
+			verification_failed_at = NULL, verification_warned_at = NULL, scans_suspended = NOT $1 AND scans_suspended 
+		WHERE id = $3`
+	_, err := r.DB.Pool.Exec(ctx, query, verified, method, domainID)
+	return err
+}
+
+// UpdateDomainScanProfile selects the port scan profile used for a domain
+func (r *Repository) UpdateDomainScanProfile(ctx context.Context, domainID string, profile string) error {
+	query := `UPDATE domains SET scan_profile = $1 WHERE id = $2`
+	_, err := r.DB.Pool.Exec(ctx, query, profile, domainID)
+	return err
+}
+
+// MarkDomainReverified records a successful periodic ownership check
+func (r *Repository) MarkDomainReverified(ctx context.Context, domainID string) error {
+	query := `UPDATE domains SET verification_checked_at = CURRENT_TIMESTAMP, verification_failed_at = NULL, verification_warned_at = NULL WHERE id = $1`
+	_, err := r.DB.Pool.Exec(ctx, query, domainID)
+	return err
+}
+
+// MarkDomainReverifyFailed records a failed periodic ownership check and returns when the failures
+// started and when the owner was last warned, if ever
+func (r *Repository) MarkDomainReverifyFailed(ctx context.Context, domainID string) (time.Time, *time.Time, error) {
+	var failedSince time.Time
+	var warnedAt *time.Time
+	query := `
+		UPDATE domains SET verification_checked_at = CURRENT_TIMESTAMP, verification_failed_at = COALESCE(verification_failed_at, CURRENT_TIMESTAMP) 
+		WHERE id = $1 
+		RETURNING verification_failed_at, verification_warned_at`
+	err := r.DB.Pool.QueryRow(ctx, query, domainID).Scan(&failedSince, &warnedAt)
+	return failedSince, warnedAt, err
+}
+
+// MarkDomainWarned records that the owner of a domain was warned about failed re-verification
+func (r *Repository) MarkDomainWarned(ctx context.Context, domainID string) error {
+	_, err := r.DB.Pool.Exec(ctx, `UPDATE domains SET verification_warned_at = CURRENT_TIMESTAMP WHERE id = $1`, domainID)
+	return err
+}
+
+// SuspendDomain revokes verification of a domain whose ownership could not be reconfirmed, stopping scheduled scans
+func (r *Repository) SuspendDomain(ctx context.Context, domainID string) error {
+	query := `UPDATE domains SET verified = false, scans_suspended = true WHERE id = $1`
+	_, err := r.DB.Pool.Exec(ctx, query, domainID)
 	return err
 }
 
 // GetDomainByNameAndOrg fetches a domain record by its root domain name and org ID
 func (r *Repository) GetDomainByNameAndOrg(ctx context.Context, domainName string, orgID string) (*models.Domain, error) {
 	var d models.Domain
-	query := `SELECT id, org_id, root_domain, verified, verification_token, created_at FROM domains WHERE root_domain = $1 AND org_id = $2 LIMIT 1`
-	err := r.DB.Pool.QueryRow(ctx, query, domainName, orgID).Scan(&d.ID, &d.OrgID, &d.RootDomain, &d.Verified, &d.VerificationToken, &d.CreatedAt)
+	query := `SELECT id, org_id, root_domain, verified, verification_token, COALESCE(verification_method, ''), verification_checked_at, verification_failed_at, scans_suspended, COALESCE(scan_profile, ''), created_at FROM domains WHERE root_domain = $1 AND org_id = $2 LIMIT 1`
+	err := r.DB.Pool.QueryRow(ctx, query, domainName, orgID).Scan(&d.ID, &d.OrgID, &d.RootDomain, &d.Verified, &d.VerificationToken, &d.VerificationMethod, &d.VerificationCheckedAt, &d.VerificationFailedAt, &d.ScansSuspended, &d.ScanProfile, &d.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 	return &d, nil
 }
 
+// AddScopeRule stores a scope rule for a domain, ignoring duplicates
+func (r *Repository) AddScopeRule(ctx context.Context, domainID string, ruleType string, pattern string) (*models.ScopeRule, error) {
+	var rule models.ScopeRule
+	query := `
+		INSERT INTO scope_rules (domain_id, rule_type, pattern) 
+		VALUES ($1, $2, $3) 
+		ON CONFLICT (domain_id, rule_type, pattern) DO UPDATE SET pattern = EXCLUDED.pattern 
+		RETURNING id, domain_id, rule_type, pattern, created_at`
+	err := r.DB.Pool.QueryRow(ctx, query, domainID, ruleType, pattern).Scan(&rule.ID, &rule.DomainID, &rule.RuleType, &rule.Pattern, &rule.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// GetScopeRules returns the scope rules of a domain
+func (r *Repository) GetScopeRules(ctx context.Context, domainID string) ([]models.ScopeRule, error) {
+	query := `SELECT id, domain_id, rule_type, pattern, created_at FROM scope_rules WHERE domain_id = $1 ORDER BY created_at`
+	rows, err := r.DB.Pool.Query(ctx, query, domainID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.ScopeRule
+	for rows.Next() {
+		var rule models.ScopeRule
+		if err := rows.Scan(&rule.ID, &rule.DomainID, &rule.RuleType, &rule.Pattern, &rule.CreatedAt); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// DeleteScopeRule removes a scope rule of a domain
+func (r *Repository) DeleteScopeRule(ctx context.Context, domainID string, ruleID string) (bool, error) {
+	tag, err := r.DB.Pool.Exec(ctx, `DELETE FROM scope_rules WHERE id = $1 AND domain_id = $2`, ruleID, domainID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
 // CheckQuota verifies if an organization has exceeded its daily scan limit
 func (r *Repository) CheckQuota(ctx context.Context, orgID string) (bool, error) {
 	var limit int
@@ -208,7 +394,7 @@ func (r *Repository) CheckQuota(ctx context.Context, orgID string) (bool, error)
 		return false, err
 	}
 
-	err = r.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM scan_runs sr JOIN domains d ON sr.domain_id = d.id WHERE d.org_id = $1 AND sr.started_at >= CURRENT_DATE", orgID).Scan(&count)
+	err = r.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM scan_runs sr LEFT JOIN domains d ON sr.domain_id = d.id LEFT JOIN ip_ranges ir ON sr.range_id = ir.id WHERE COALESCE(d.org_id, ir.org_id) = $1 AND sr.started_at >= CURRENT_DATE", orgID).Scan(&count)
 	if err != nil {
 		return false, err
 	}
@@ -226,8 +412,8 @@ func (r *Repository) CreateAuditLog(ctx context.Context, log *models.AuditLog) e
 // GetDomainByID fetches a domain by ID
 func (r *Repository) GetDomainByID(ctx context.Context, domainID string) (*models.Domain, error) {
 	var d models.Domain
-	query := `SELECT id, org_id, root_domain, verified, verification_token, created_at FROM domains WHERE id = $1`
-	err := r.DB.Pool.QueryRow(ctx, query, domainID).Scan(&d.ID, &d.OrgID, &d.RootDomain, &d.Verified, &d.VerificationToken, &d.CreatedAt)
+	query := `SELECT id, org_id, root_domain, verified, verification_token, COALESCE(verification_method, ''), verification_checked_at, verification_failed_at, scans_suspended, COALESCE(scan_profile, ''), created_at FROM domains WHERE id = $1`
+	err := r.DB.Pool.QueryRow(ctx, query, domainID).Scan(&d.ID, &d.OrgID, &d.RootDomain, &d.Verified, &d.VerificationToken, &d.VerificationMethod, &d.VerificationCheckedAt, &d.VerificationFailedAt, &d.ScansSuspended, &d.ScanProfile, &d.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -235,7 +421,7 @@ func (r *Repository) GetDomainByID(ctx context.Context, domainID string) (*model
 }
 // GetAssetsByDomain retrieves all discovered assets for a root domain
 func (r *Repository) GetAssetsByDomain(ctx context.Context, domainID string) ([]models.Asset, error) {
-	query := `SELECT id, domain_id, subdomain, ip_address, last_seen FROM assets WHERE domain_id = $1 ORDER BY last_seen DESC`
+	query := `SELECT id, domain_id, subdomain, host(ip_address), family(ip_address), COALESCE(ptr, ''), COALESCE(provider, ''), cdn, last_seen FROM assets WHERE domain_id = $1 ORDER BY last_seen DESC`
 	rows, err := r.DB.Pool.Query(ctx, query, domainID)
 	if err != nil {
 		return nil, err
@@ -245,7 +431,7 @@ func (r *Repository) GetAssetsByDomain(ctx context.Context, domainID string) ([]
 	var assets []models.Asset
 	for rows.Next() {
 		var a models.Asset
-		err := rows.Scan(&a.ID, &a.DomainID, &a.Subdomain, &a.IPAddress, &a.LastSeen)
+		err := rows.Scan(&a.ID, &a.DomainID, &a.Subdomain, &a.IPAddress, &a.IPVersion, &a.PTR, &a.Provider, &a.CDN, &a.LastSeen)
 		if err != nil {
 			return nil, err
 		}
@@ -254,24 +440,51 @@ func (r *Repository) GetAssetsByDomain(ctx context.Context, domainID string) ([]
 	return assets, nil
 }
 
+// GetHostsByDomain retrieves discovered subdomains of a root domain with all of their addresses
+func (r *Repository) GetHostsByDomain(ctx context.Context, domainID string) ([]models.Host, error) {
+	query := `
+		SELECT COALESCE(subdomain, ''), array_agg(host(ip_address) ORDER BY ip_address), MAX(last_seen)
+		FROM assets
+		WHERE domain_id = $1
+		GROUP BY subdomain
+		ORDER BY MAX(last_seen) DESC
+	`
+	rows, err := r.DB.Pool.Query(ctx, query, domainID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hosts []models.Host
+	for rows.Next() {
+		var h models.Host
+		err := rows.Scan(&h.Subdomain, &h.Addresses, &h.LastSeen)
+		if err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts, nil
+}
+
 // GetGlobalStats calculates real counts across the platform or org
 func (r *Repository) GetGlobalStats(ctx context.Context, orgID string) (map[string]interface{}, error) {
 	var totalAssets, criticalRisks, highRisks, scansCompleted int
 
 	// Count Assets
-	err := r.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM assets a JOIN domains d ON a.domain_id = d.id WHERE d.org_id = $1", orgID).Scan(&totalAssets)
+	err := r.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM assets a LEFT JOIN domains d ON a.domain_id = d.id LEFT JOIN ip_ranges ir ON a.range_id = ir.id WHERE COALESCE(d.org_id, ir.org_id) = $1", orgID).Scan(&totalAssets)
 	if err != nil { return nil, err }
 
 	// Count Critical Risks
-	err = r.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM findings f JOIN services s ON f.service_id = s.id JOIN assets a ON s.asset_id = a.id JOIN domains d ON a.domain_id = d.id WHERE d.org_id = $1 AND f.severity = 'critical'", orgID).Scan(&criticalRisks)
+	err = r.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM findings f LEFT JOIN services s ON f.service_id = s.id LEFT JOIN assets a ON s.asset_id = a.id LEFT JOIN domains d ON COALESCE(f.domain_id, a.domain_id) = d.id LEFT JOIN ip_ranges ir ON f.range_id = ir.id WHERE COALESCE(d.org_id, ir.org_id) = $1 AND f.severity = 'critical'", orgID).Scan(&criticalRisks)
 	if err != nil { return nil, err }
 
 	// Count High Risks
-	err = r.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM findings f JOIN services s ON f.service_id = s.id JOIN assets a ON s.asset_id = a.id JOIN domains d ON a.domain_id = d.id WHERE d.org_id = $1 AND f.severity = 'high'", orgID).Scan(&highRisks)
+	err = r.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM findings f LEFT JOIN services s ON f.service_id = s.id LEFT JOIN assets a ON s.asset_id = a.id LEFT JOIN domains d ON COALESCE(f.domain_id, a.domain_id) = d.id LEFT JOIN ip_ranges ir ON f.range_id = ir.id WHERE COALESCE(d.org_id, ir.org_id) = $1 AND f.severity = 'high'", orgID).Scan(&highRisks)
 	if err != nil { return nil, err }
 
 	// Count Scans
-	err = r.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM scan_runs sr JOIN domains d ON sr.domain_id = d.id WHERE d.org_id = $1", orgID).Scan(&scansCompleted)
+	err = r.DB.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM scan_runs sr LEFT JOIN domains d ON sr.domain_id = d.id LEFT JOIN ip_ranges ir ON sr.range_id = ir.id WHERE COALESCE(d.org_id, ir.org_id) = $1", orgID).Scan(&scansCompleted)
 	if err != nil { return nil, err }
 
 	return map[string]interface{}{
@@ -285,7 +498,9 @@ func (r *Repository) GetGlobalStats(ctx context.Context, orgID string) (map[stri
 // GetServicesByDomain retrieves all services for all assets of a domain
 func (r *Repository) GetServicesByDomain(ctx context.Context, domainID string) ([]map[string]interface{}, error) {
 	query := `
-		SELECT s.port, s.protocol, s.fingerprint, s.technology, a.subdomain as asset_name, f.severity as risk
+		SELECT s.port, s.protocol, s.fingerprint, s.technology, a.subdomain as asset_name, host(a.ip_address), family(a.ip_address), f.severity as risk, COALESCE(s.tls_grade, ''), 
+			COALESCE(s.product, ''), COALESCE(s.version, ''), COALESCE(s.cpe, ''), COALESCE(s.unauthenticated, FALSE), COALESCE(s.http_title, ''), COALESCE(s.favicon_hash, 0), COALESCE(s.technologies, '{}'),
+			COALESCE(s.content_hash, ''), s.http_details
 		FROM services s
 		JOIN assets a ON s.asset_id = a.id
 		LEFT JOIN findings f ON f.service_id = s.id
@@ -301,9 +516,18 @@ func (r *Repository) GetServicesByDomain(ctx context.Context, domainID string) (
 	var services []map[string]interface{}
 	for rows.Next() {
 		var port int
-		var protocol, fingerprint, technology, assetName string
+		var protocol, fingerprint, technology, assetName, ip string
+		var ipVersion int
 		var risk *string
-		err := rows.Scan(&port, &protocol, &fingerprint, &technology, &assetName, &risk)
+		var tlsGrade, product, version, cpe string
+		var unauthenticated bool
+		var httpTitle string
+		var faviconHash int32
+		var technologies []string
+		var contentHash string
+		var httpDetails []byte
+		err := rows.Scan(&port, &protocol, &fingerprint, &technology, &assetName, &ip, &ipVersion, &risk, &tlsGrade, &product, &version, &cpe, &unauthenticated,
+			&httpTitle, &faviconHash, &technologies, &contentHash, &httpDetails)
 		if err != nil {
 			return nil, err
 		}
@@ -317,9 +541,21 @@ func (r *Repository) GetServicesByDomain(ctx context.Context, domainID string) (
 			"port":       port,
 			"proto":      protocol,
 			"asset":      assetName,
+			"ip":         ip,
+			"ipVersion":  ipVersion,
 			"service":    technology, // technology usually holds the service name
 			"risk":       rVal,
 			"fingerprint": fingerprint,
+			"tlsGrade":   tlsGrade,
+			"product":    product,
+			"version":    version,
+			"cpe":        cpe,
+			"unauthenticated": unauthenticated,
+			"httpTitle":  httpTitle,
+			"faviconHash": faviconHash,
+			"technologies": technologies,
+			"contentHash": contentHash,
+			"httpDetails": json.RawMessage(httpDetails),
 		})
 	}
 	return services, nil
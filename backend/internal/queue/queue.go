@@ -13,6 +13,7 @@ type Job struct {
 	ID       string
 	Domain   string
 	DomainID string
+	RangeID  string // Set instead of DomainID for IP range scans; Domain then holds the CIDR
 	Status   string // "pending", "running", "completed", "failed"
 	Result   interface{}
 	Error    error
@@ -69,6 +70,29 @@ func (q *Queue) Enqueue(domain, domainID string) string {
 	return jobID
 }
 
+// EnqueueRange adds an IP range scan job to the queue
+func (q *Queue) EnqueueRange(cidr, rangeID string) string {
+	jobID := uuid.New().String()
+	job := &Job{
+		ID:        jobID,
+		Domain:    cidr,
+		RangeID:   rangeID,
+		Status:    "pending",
+		CreatedAt: time.Now(),
+	}
+
+	q.mu.Lock()
+	q.jobs[jobID] = job
+	q.mu.Unlock()
+
+	select {
+	case q.workers <- job:
+	default:
+	}
+
+	return jobID
+}
+
 // GetJob retrieves a job by ID
 func (q *Queue) GetJob(jobID string) (*Job, bool) {
 	q.mu.RLock()
@@ -49,7 +49,13 @@ func (w *Worker) processJob(ctx context.Context, job *Job) {
 	w.queue.UpdateJobStatus(job.ID, "running")
 
 	// Run the scan
-	result, err := w.orchestrator.RunScan(ctx, job.Domain, job.DomainID)
+	var result *scanner.ScanResult
+	var err error
+	if job.RangeID != "" {
+		result, err = w.orchestrator.RunRangeScan(ctx, job.RangeID)
+	} else {
+		result, err = w.orchestrator.RunScan(ctx, job.Domain, job.DomainID)
+	}
 	
 	// Set result
 	w.queue.SetJobResult(job.ID, result, err)
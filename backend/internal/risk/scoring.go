@@ -1,7 +1,10 @@
 package risk
 
 import (
+	"fmt"
+
 	"cortex-backend/internal/container"
+	"cortex-backend/internal/fingerprinting"
 )
 
 type Severity string
@@ -19,9 +22,11 @@ type Exposure struct {
 	Severity    Severity
 	Description string
 	Remediation string
-	AssetIP     string // IP address of the asset
-	Port        int    // Port number
-	Technology  string // Technology detected
+	AssetIP     string   // IP address of the asset
+	Port        int      // Port number
+	Technology  string   // Technology detected
+	Hostname    string   // Hostname for DNS-level findings without an IP/port
+	Evidence    []string // What the probe observed, e.g. repository names
 }
 
 // AttackPath represents a chain of vulnerabilities that could be exploited together
@@ -211,3 +216,44 @@ func Classify(port int, tech container.Technology, fingerprint string) Exposure
 		Remediation: "Verify if this service is intended to be public.",
 	}
 }
+
+// serviceNames maps probed services to display names; data stores are labelled so attack paths pick them up
+var serviceNames = map[string]string{
+	"redis":      "Redis Database",
+	"mongodb":    "MongoDB Database",
+	"postgresql": "PostgreSQL Database",
+	"etcd":       "etcd Key-Value Store",
+}
+
+// ClassifyService assigns risk to a service identified by a protocol probe
+func ClassifyService(info *fingerprinting.ServiceInfo) Exposure {
+	name := serviceNames[info.Service]
+	if name == "" {
+		name = info.Service
+	}
+	version := ""
+	if info.Version != "" {
+		version = " " + info.Version
+	}
+
+	if info.Unauthenticated {
+		exposure := Exposure{
+			Type:        "Unauthenticated " + name,
+			Severity:    Critical,
+			Description: fmt.Sprintf("%s%s is reachable from the internet and answered a read-only request without credentials (%s). Anyone can read, and likely modify, its data.", name, version, info.Detail),
+			Remediation: "Require authentication and bind the service to a private interface or restrict it with firewall rules.",
+		}
+		if info.Service == "etcd" {
+			exposure.Description += " If this etcd backs a Kubernetes cluster it holds every Secret and service account token."
+			exposure.Remediation = "Enable client certificate authentication (--client-cert-auth) and never expose etcd outside the control plane network."
+		}
+		return exposure
+	}
+
+	return Exposure{
+		Type:        "Exposed " + name,
+		Severity:    Medium,
+		Description: fmt.Sprintf("%s%s accepts connections from the internet (%s). It requires credentials, but is exposed to brute-force attacks and protocol vulnerabilities.", name, version, info.Detail),
+		Remediation: "Restrict access to trusted networks with firewall rules or a private network.",
+	}
+}
@@ -0,0 +1,127 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"cortex-backend/internal/tasks"
+	"cortex-backend/pkg/models"
+	"github.com/google/uuid"
+)
+
+const (
+	agentOnlineWindow = 2 * time.Minute  // An agent that polled this recently receives tasks
+	agentClaimTimeout = time.Minute      // An unclaimed task is withdrawn and run in-process after this
+	agentTaskTimeout  = 15 * time.Minute // A claimed task that has not reported back by then is abandoned
+	agentTaskPoll     = time.Second
+)
+
+// runTask executes a network task on one of the org's agents, or in-process when the org has no agent
+// online or the agents cannot deliver, and decodes its result. Persistence and classification of the
+// result always happen here. A task the agents failed to deliver is counted on the scan run and audited,
+// since it probed from Cortex's network instead of the org's.
+func (o *Orchestrator) runTask(ctx context.Context, runID string, orgID uuid.UUID, kind string, payload interface{}, result interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	task := tasks.Task{Kind: kind, Payload: data}
+
+	raw, err := o.runOnAgent(ctx, orgID, task)
+	if raw == nil {
+		if err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			log.Printf("[Agent] Running %s task of scan %s in-process: %v", kind, runID, err)
+			o.recordAgentFallback(ctx, runID, orgID, kind, err)
+		}
+		raw, err = tasks.Execute(ctx, task)
+		if err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(raw, result)
+}
+
+// runOnAgent queues a task for the org's agents and waits for its result. A nil result means the
+// task was not run remotely; it comes with an error unless the org has no agents at all.
+func (o *Orchestrator) runOnAgent(ctx context.Context, orgID uuid.UUID, task tasks.Task) (json.RawMessage, error) {
+	if orgID == uuid.Nil {
+		return nil, nil
+	}
+	now := time.Now()
+	online, err := o.Repo.CountOnlineAgents(ctx, orgID, now.Add(-agentOnlineWindow), now.Add(-agentTaskTimeout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to count online agents: %v", err)
+	}
+	if online == 0 {
+		// Orgs without agents scan from Cortex's network by design; orgs with agents expect them to be used
+		if registered, _ := o.Repo.CountAgents(ctx, orgID); registered > 0 {
+			return nil, fmt.Errorf("none of the org's %d agents has polled within %s", registered, agentOnlineWindow)
+		}
+		return nil, nil
+	}
+
+	id, err := o.Repo.CreateAgentTask(ctx, orgID, task.Kind, task.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to queue task: %v", err)
+	}
+
+	queued := time.Now()
+	ticker := time.NewTicker(agentTaskPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			o.Repo.ExpireAgentTask(context.Background(), id, "scan cancelled")
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		t, err := o.Repo.GetAgentTask(ctx, id)
+		if err != nil {
+			continue
+		}
+		switch t.Status {
+		case "completed":
+			return t.Result, nil
+		case "failed":
+			return nil, fmt.Errorf("agent task %s failed: %s", id, t.Error)
+		case "pending":
+			if time.Since(queued) > agentClaimTimeout {
+				// The agent may claim it in the meantime; only withdraw it if it is still unclaimed
+				if cancelled, _ := o.Repo.CancelAgentTask(ctx, id); cancelled {
+					return nil, fmt.Errorf("no agent claimed task %s within %s", id, agentClaimTimeout)
+				}
+			}
+		case "running":
+			if time.Since(queued) > agentTaskTimeout {
+				o.Repo.ExpireAgentTask(ctx, id, "timed out")
+				return nil, fmt.Errorf("agent task %s timed out after %s", id, agentTaskTimeout)
+			}
+		default:
+			return nil, fmt.Errorf("agent task %s was %s", id, t.Status)
+		}
+	}
+}
+
+// recordAgentFallback notes on the scan run and in the audit log that a task ran in-process
+func (o *Orchestrator) recordAgentFallback(ctx context.Context, runID string, orgID uuid.UUID, kind string, reason error) {
+	if err := o.Repo.RecordAgentFallback(ctx, runID); err != nil {
+		log.Printf("[Agent] Failed to record fallback on scan %s: %v", runID, err)
+	}
+	metadata, _ := json.Marshal(map[string]string{
+		"scan_run": runID,
+		"kind":     kind,
+		"reason":   reason.Error(),
+	})
+	o.Repo.CreateAuditLog(ctx, &models.AuditLog{
+		OrgID:    &orgID,
+		Action:   "AGENT_FALLBACK",
+		Metadata: string(metadata),
+	})
+}
@@ -4,29 +4,34 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"net"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"cortex-backend/internal/alerting"
-	"cortex-backend/internal/container"
 	"cortex-backend/internal/discovery"
-	"cortex-backend/internal/fingerprinting"
+	"cortex-backend/internal/hosting"
 	"cortex-backend/internal/persistence"
 	"cortex-backend/internal/risk"
 	"cortex-backend/internal/scanning"
+	"cortex-backend/internal/scope"
+	"cortex-backend/internal/tasks"
 	"cortex-backend/pkg/models"
 )
 
 type Orchestrator struct {
-	Repo         *persistence.Repository
-	AlertHandler *alerting.AlertHandler
+	Repo            *persistence.Repository
+	AlertHandler    *alerting.AlertHandler
+	Providers       *hosting.Database
+	PassiveCacheTTL time.Duration // How long cached Certificate Transparency results are reused without polling
 }
 
 func NewOrchestrator(repo *persistence.Repository) *Orchestrator {
 	return &Orchestrator{
-		Repo:         repo,
-		AlertHandler: alerting.NewAlertHandler(),
+		Repo:            repo,
+		AlertHandler:    alerting.NewAlertHandler(),
+		Providers:       hosting.Load(),
+		PassiveCacheTTL: passiveCacheTTL(),
 	}
 }
 
@@ -55,8 +60,11 @@ func (o *Orchestrator) RunScan(ctx context.Context, domainName string, domainID
 		Metadata: fmt.Sprintf(`{"domain": "%s"}`, domainName),
 	})
 
+	// Port scan profile - the domain's choice, as long as the org's plan still includes it
+	profile := o.scanProfile(ctx, domain.OrgID, domain.ScanProfile, domain.RootDomain)
+
 	// Track Scan Run
-	runID, _ := o.Repo.CreateScanRun(ctx, domainID)
+	runID, _ := o.Repo.CreateScanRun(ctx, domainID, profile.Name)
 
 	// Fetch previous findings for delta detection
 	previousFindings, _ := o.Repo.GetLatestFindingsForDomain(ctx, domainID)
@@ -66,37 +74,56 @@ func (o *Orchestrator) RunScan(ctx context.Context, domainName string, domainID
 		prevMap[key] = true
 	}
 
+	// Scope rules - enforced on every target before it is probed
+	scopeRules, _ := o.Repo.GetScopeRules(ctx, domainID)
+	policy, err := scope.Compile(scopeRules)
+	if err != nil {
+		o.Repo.UpdateScanRunStatus(ctx, runID, "failed")
+		return nil, fmt.Errorf("invalid scope rules: %v", err)
+	}
+
 	// 1. Discovery (Active + Passive + TLS Certificate Analysis)
 	dnsScanner := discovery.NewScanner()
-	activeAssets, _ := dnsScanner.EnumerateSubdomains(ctx, domainName)
-	passiveAssets, _ := dnsScanner.PassiveDiscovery(ctx, domainName)
-	
-	// TLS Certificate Analysis - Extract SANs
-	tlsSANs, err := discovery.ExtractSANs(domainName)
-	if err == nil && len(tlsSANs) > 0 {
-		log.Printf("[Discovery] Found %d SANs from TLS certificate", len(tlsSANs))
-		// Convert SANs to discovery results by resolving to IPs
-		for _, san := range tlsSANs {
-			// Skip if it's the root domain (already discovered)
-			if san == domainName {
-				continue
-			}
-			// Resolve SAN to IPs using net.LookupHost
-			ips, err := net.LookupHost(san)
-			if err == nil && len(ips) > 0 {
-				// Extract subdomain from SAN
-				subdomain := san
-				if strings.HasSuffix(san, "."+domainName) {
-					subdomain = strings.TrimSuffix(san, "."+domainName)
-				}
-				passiveAssets = append(passiveAssets, discovery.Result{
-					Subdomain: subdomain,
-					IPs:       ips,
-				})
-			}
+	// Explicitly included names are resolved even if no discovery source knows them
+	var active tasks.DiscoveryResult
+	if err := o.runTask(ctx, runID, domain.OrgID, tasks.KindDiscovery, tasks.DiscoveryPayload{Domain: domainName, Hosts: policy.ExplicitHosts()}, &active); err != nil {
+		log.Printf("[Discovery] Active enumeration of %s failed: %v", domainName, err)
+	}
+	activeAssets := active.Assets
+	passiveAssets := o.passiveDiscovery(ctx, dnsScanner, domainName)
+
+	state := &scanState{
+		runID:      runID,
+		domainID:   uuid.MustParse(domainID),
+		domainName: domainName,
+		prevMap:    prevMap,
+		resolver:   dnsScanner,
+		orgID:      domain.OrgID,
+		scope:      policy,
+	}
+	// Checks for probes outside the port scan, auditing what the scope excludes
+	allowHost := func(sub string) bool {
+		if ok, reason := policy.AllowsHost(sub); !ok {
+			o.recordExclusion(ctx, state, state.hostname(sub), reason)
+			return false
 		}
+		return true
 	}
-	
+	allowAddress := func(host, ip string) bool {
+		if ok, reason := policy.AllowsIP(ip); !ok {
+			o.recordExclusion(ctx, state, host+" ("+ip+")", reason)
+			return false
+		}
+		return true
+	}
+
+	// Zone Transfer - records from an open AXFR feed back into discovery
+	zoneTransfers := dnsScanner.CheckZoneTransfers(ctx, domainName, allowAddress)
+	for _, zt := range zoneTransfers {
+		log.Printf("[Discovery] Nameserver %s allowed AXFR (%d records, truncated: %v)", zt.Nameserver, zt.RecordCount, zt.Truncated)
+		passiveAssets = append(passiveAssets, zt.Hosts...)
+	}
+
 	// Merge assets
 	assetMap := make(map[string]discovery.Result)
 	for _, a := range activeAssets { assetMap[a.Subdomain] = a }
@@ -116,105 +143,103 @@ func (o *Orchestrator) RunScan(ctx context.Context, domainName string, domainID
 	}
 
 	var assets []discovery.Result
-	for _, a := range assetMap { assets = append(assets, a) }
+	for _, a := range assetMap {
+		if scoped, ok := o.applyScope(ctx, state, a); ok {
+			assets = append(assets, scoped)
+		}
+	}
+
+	// Zone Transfer findings - one per nameserver that allowed AXFR
+	for _, zt := range zoneTransfers {
+		count := fmt.Sprint(zt.RecordCount)
+		if zt.Truncated {
+			count = "more than " + count
+		}
+		exposure := risk.Exposure{
+			Type:        "DNS Zone Transfer Allowed",
+			Severity:    risk.High,
+			Description: fmt.Sprintf("Nameserver %s (%s) allows anonymous AXFR of %s, disclosing %s DNS records including internal hostnames and addresses.", zt.Nameserver, zt.Address, domainName, count),
+			Remediation: "Restrict zone transfers to your secondary nameservers only (e.g. allow-transfer in BIND, or TSIG-signed transfers).",
+			Technology:  "dns",
+			Hostname:    zt.Nameserver,
+		}
+		o.recordDomainFinding(ctx, state, exposure)
+	}
+
+	// Email Security - SPF, DMARC, DKIM and MTA-STS posture of the root domain
+	emailReport := dnsScanner.CheckEmailSecurity(ctx, domainName, allowHost, allowAddress)
+	for _, issue := range emailReport.Issues {
+		exposure := risk.Exposure{
+			Type:        issue.Title,
+			Severity:    risk.Severity(issue.Severity),
+			Description: issue.Description,
+			Remediation: issue.Remediation,
+			Technology:  "email",
+			Hostname:    domainName,
+		}
+		o.recordDomainFinding(ctx, state, exposure)
+	}
+
+	// Subdomain Takeover - follow CNAME chains of every known and common name
+	candidateMap := make(map[string]bool)
+	for _, sub := range dnsScanner.CommonSubdomains { candidateMap[sub] = true }
+	for sub := range assetMap { candidateMap[sub] = true }
+	var candidates []string
+	for sub := range candidateMap {
+		if allowHost(sub) {
+			candidates = append(candidates, sub)
+		}
+	}
+
+	for _, t := range dnsScanner.CheckTakeovers(ctx, domainName, candidates, allowAddress) {
+		host := state.hostname(t.Subdomain)
+		exposure := risk.Exposure{
+			Type:        "Subdomain Takeover",
+			Severity:    risk.High,
+			Description: fmt.Sprintf("%s points to an unclaimed %s resource (%s -> %s). %s. An attacker can claim the resource and serve content on your domain.", host, t.Service, host, strings.Join(t.Chain, " -> "), t.Evidence),
+			Remediation: t.Remediation,
+			Technology:  t.Service,
+			Hostname:    host,
+		}
+		o.recordDomainFinding(ctx, state, exposure)
+	}
 
 	// 2. Scan & Analysis Pipeline
 	portScanner := scanning.NewScanner()
-	var allFindings []risk.Exposure
-	var newFindings []risk.Exposure
+	portScanner.ApplyProfile(profile)
+	portScanner.Providers = o.Providers
+	portScanner.TargetPorts = o.scopedPorts(ctx, state, portScanner.TargetPorts)
 
 	for _, assetResult := range assets {
 		if len(assetResult.IPs) == 0 {
 			continue
 		}
-		ip := assetResult.IPs[0]
 
-		// Persistence: Save asset
-		assetModel := &models.Asset{
-			DomainID:  uuid.MustParse(domainID),
-			Subdomain: assetResult.Subdomain,
-			IPAddress: ip,
-		}
-		o.Repo.SaveAsset(ctx, assetModel)
-
-		ports, _ := portScanner.ScanPorts(ctx, ip)
-		for _, p := range ports {
-			// Save service
-			serviceModel := &models.Service{
-				AssetID:  assetModel.ID,
-				Port:     p.Port,
-				Protocol: p.Protocol,
-			}
-
-			url := "http://"
-			if p.Port == 443 || p.Port == 2376 || p.Port == 6443 {
-				url = "https://"
-			}
-			url += fmt.Sprintf("%s:%d", ip, p.Port)
-
-			fp, _ := fingerprinting.HTTPFingerprint(ctx, url)
-			fpStr := ""
-			if fp != nil {
-				fpStr = fp.Server + " " + fp.BodySnippet
-			}
-
-			tech := container.Detect(p.Port, fpStr)
-			serviceModel.Technology = string(tech)
-			serviceModel.Fingerprint = fpStr
-			o.Repo.SaveService(ctx, serviceModel)
-
-			// Basic Classification
-			exposure := risk.Classify(p.Port, tech, fpStr)
-			
-			// Advanced Probing (Phase 3)
-			advSev, advTitle, advDesc := container.ProbeAdvanced(ctx, ip, p.Port, tech)
-			if advSev != "" {
-				exposure = risk.Exposure{
-					Type:        advTitle,
-					Severity:    risk.Severity(advSev),
-					Description: advDesc,
-					Remediation: exposure.Remediation, // Fallback to basic remediation
-					AssetIP:     ip,
-					Port:        p.Port,
-					Technology:  string(tech),
-				}
-				// Specific remediation for advanced probes
-				if advTitle == "Kubernetes Kubelet API Anonymous Access" {
-					exposure.Remediation = "Set --anonymous-auth=false and --authorization-mode=Webhook in Kubelet configuration."
-				} else if advTitle == "Exposed Docker Remote API (Unauthenticated)" {
-					exposure.Remediation = "Disable TCP access to the Docker API or enforce MTLS authentication using certificates."
-				}
-			} else {
-				// Add asset context to basic exposure
-				exposure.AssetIP = ip
-				exposure.Port = p.Port
-				exposure.Technology = string(tech)
-			}
+		o.scanHost(ctx, state, portScanner, assetResult)
+	}
 
-			if exposure.Severity != risk.Info {
-				allFindings = append(allFindings, exposure)
-				
-				key := fmt.Sprintf("%s-%s", exposure.Type, exposure.Severity)
-				if !prevMap[key] {
-					newFindings = append(newFindings, exposure)
-				}
-
-				findingModel := &models.Finding{
-					ServiceID:   serviceModel.ID,
-					Type:        exposure.Type,
-					Severity:    string(exposure.Severity),
-					Description: exposure.Description,
-					Remediation: exposure.Remediation,
-				}
-				o.Repo.SaveFinding(ctx, findingModel)
+	// Certificate SANs - names seen on any TLS port feed back into discovery until no new hosts appear
+	for {
+		sanHosts := state.newSANHosts(ctx, assetMap)
+		if len(sanHosts) == 0 {
+			break
+		}
+		log.Printf("[Discovery] Found %d new hosts from TLS certificate SANs", len(sanHosts))
+		for _, h := range sanHosts {
+			assetMap[h.Subdomain] = h
+			scoped, ok := o.applyScope(ctx, state, h)
+			if !ok {
+				continue
 			}
+			assets = append(assets, scoped)
+			o.scanHost(ctx, state, portScanner, scoped)
 		}
 	}
 
 	// 3. Advanced Attack Path Mapping
-	if len(allFindings) > 1 {
-		log.Printf("[AttackPath] Analyzing chains for %d findings...", len(allFindings))
-		attackPaths := risk.AnalyzeAttackPaths(allFindings)
+	if len(state.allFindings) > 1 {
+		log.Printf("[AttackPath] Analyzing chains for %d findings...", len(state.allFindings))
+		attackPaths := risk.AnalyzeAttackPaths(state.allFindings)
 		if len(attackPaths) > 0 {
 			log.Printf("[AttackPath] Identified %d attack paths", len(attackPaths))
 			for _, path := range attackPaths {
@@ -231,19 +256,19 @@ func (o *Orchestrator) RunScan(ctx context.Context, domainName string, domainID
 	o.Repo.CreateAuditLog(ctx, &models.AuditLog{
 		OrgID:  &domain.OrgID,
 		Action: "SCAN_COMPLETE",
-		Metadata: fmt.Sprintf(`{"domain": "%s", "findings": %d}`, domainName, len(allFindings)),
+		Metadata: fmt.Sprintf(`{"domain": "%s", "findings": %d}`, domainName, len(state.allFindings)),
 	})
 
 	// Send alerts for new critical/high findings
-	if len(newFindings) > 0 {
-		if err := o.AlertHandler.SendAlert(domainName, newFindings); err != nil {
+	if len(state.newFindings) > 0 {
+		if err := o.AlertHandler.SendAlert(domainName, state.newFindings); err != nil {
 			log.Printf("[Alert] Failed to send alert: %v", err)
 		}
 	}
 
 	return &ScanResult{
 		Assets:      assets,
-		AllFindings: allFindings,
-		NewFindings: newFindings,
+		AllFindings: state.allFindings,
+		NewFindings: state.newFindings,
 	}, nil
 }
@@ -0,0 +1,76 @@
+package scanner
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"cortex-backend/internal/discovery"
+)
+
+// passiveCacheTTL reads how long cached CT names are reused from PASSIVE_CACHE_TTL (e.g. "12h"), defaulting to 24h
+func passiveCacheTTL() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("PASSIVE_CACHE_TTL")); err == nil && d > 0 {
+		return d
+	}
+	return 24 * time.Hour
+}
+
+// passiveDiscovery returns Certificate Transparency subdomains of rootDomain through the shared Postgres cache.
+// Fresh cache entries are used as-is; stale ones are topped up with only the certificates logged since the
+// last poll. The full crt.sh history is downloaded once, the first time any org scans the domain, and the
+// Cert Spotter cursor is then seeded at its newest certificate instead of re-reading what crt.sh returned.
+func (o *Orchestrator) passiveDiscovery(ctx context.Context, dnsScanner *discovery.Scanner, rootDomain string) []discovery.Result {
+	cache, _ := o.Repo.GetPassiveCache(ctx, rootDomain)
+
+	if cache != nil && time.Since(cache.FetchedAt) < o.PassiveCacheTTL {
+		log.Printf("[Discovery] Using %d cached CT names for %s", len(cache.Subdomains), rootDomain)
+		return dnsScanner.ResolveSubdomains(ctx, rootDomain, cache.Subdomains)
+	}
+
+	var known, updates []string
+	var historyErr, err error
+	cursor := ""
+	if cache != nil {
+		known = cache.Subdomains
+		cursor = cache.CTCursor
+	} else {
+		known, historyErr = dnsScanner.FetchCTHistory(ctx, rootDomain)
+		if historyErr != nil {
+			log.Printf("[Discovery] crt.sh history fetch failed for %s: %v", rootDomain, historyErr)
+		}
+	}
+
+	if cache == nil && historyErr == nil {
+		// The history already holds every certificate Cert Spotter would return from the start
+		cursor, err = dnsScanner.LatestCTCursor(ctx, rootDomain)
+		if err != nil {
+			log.Printf("[Discovery] Cert Spotter cursor seek failed for %s: %v", rootDomain, err)
+		}
+	} else {
+		updates, cursor, err = dnsScanner.FetchCTUpdates(ctx, rootDomain, cursor)
+		if err != nil {
+			log.Printf("[Discovery] CT update poll failed for %s: %v", rootDomain, err)
+		}
+		log.Printf("[Discovery] Fetched %d CT names for %s since last poll", len(updates), rootDomain)
+	}
+
+	all := append(known, updates...)
+	// Without the full history the cache would stay incomplete, so the next scan retries it
+	if historyErr == nil && (len(all) > 0 || cursor != "") {
+		if err := o.Repo.SavePassiveCache(ctx, rootDomain, all, cursor); err != nil {
+			log.Printf("[Discovery] Failed to update passive cache for %s: %v", rootDomain, err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var subs []string
+	for _, sub := range all {
+		if !seen[sub] {
+			seen[sub] = true
+			subs = append(subs, sub)
+		}
+	}
+	return dnsScanner.ResolveSubdomains(ctx, rootDomain, subs)
+}
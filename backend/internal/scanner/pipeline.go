@@ -0,0 +1,558 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"cortex-backend/internal/container"
+	"cortex-backend/internal/discovery"
+	"cortex-backend/internal/outbound"
+	"cortex-backend/internal/risk"
+	"cortex-backend/internal/scanning"
+	"cortex-backend/internal/scope"
+	"cortex-backend/internal/tasks"
+	"cortex-backend/pkg/models"
+	"github.com/google/uuid"
+)
+
+// scanState carries the per-run context shared by every check in a scan
+type scanState struct {
+	runID       string
+	domainID    uuid.UUID
+	domainName  string
+	rangeID     *uuid.UUID // Set instead of domainID when scanning a registered IP range
+	mu          sync.Mutex // Range scans probe several addresses concurrently
+	prevMap     map[string]bool // Type-Severity keys of previous findings for delta detection
+	resolver    *discovery.Scanner
+	allFindings []risk.Exposure
+	newFindings []risk.Exposure
+	sans        map[string]bool // DNS names seen in certificates, fed back into discovery
+	orgID       uuid.UUID
+	scope       *scope.Policy   // Nil allows everything
+	excluded    map[string]bool // Targets already audited as out of scope in this run
+}
+
+// track adds a finding to the run results and marks it new if it was not seen previously
+func (st *scanState) track(exposure risk.Exposure) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.allFindings = append(st.allFindings, exposure)
+	if !st.prevMap[fmt.Sprintf("%s-%s", exposure.Type, exposure.Severity)] {
+		st.newFindings = append(st.newFindings, exposure)
+	}
+}
+
+// addSANs remembers certificate names so hosts that discovery missed can be scanned afterwards
+func (st *scanState) addSANs(names []string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.sans == nil {
+		st.sans = make(map[string]bool)
+	}
+	for _, name := range names {
+		st.sans[name] = true
+	}
+}
+
+// findingDomainID returns the domain findings are attributed to, or nil for range scans
+func (st *scanState) findingDomainID() *uuid.UUID {
+	if st.rangeID != nil {
+		return nil
+	}
+	return &st.domainID
+}
+
+// hostname returns the fully qualified name of a subdomain of the scanned domain
+func (st *scanState) hostname(subdomain string) string {
+	if subdomain == "" {
+		return st.domainName
+	}
+	return subdomain + "." + st.domainName
+}
+
+// serviceFinding is a finding waiting to be deduplicated across the addresses of a host
+type serviceFinding struct {
+	serviceID uuid.UUID
+	exposure  risk.Exposure
+}
+
+// scanProfile returns the port scan profile selected for a domain or range (target), falling back to the
+// default profile if none is selected, it no longer exists, or the org's plan no longer includes it
+func (o *Orchestrator) scanProfile(ctx context.Context, orgID uuid.UUID, selected, target string) *scanning.Profile {
+	if selected == "" || selected == scanning.DefaultProfile {
+		return scanning.GetProfile(scanning.DefaultProfile)
+	}
+
+	profile := scanning.GetProfile(selected)
+	if profile == nil {
+		log.Printf("[Scan] Unknown scan profile %q for %s, using %s", selected, target, scanning.DefaultProfile)
+		return scanning.GetProfile(scanning.DefaultProfile)
+	}
+
+	plan, _ := o.Repo.GetOrgPlan(ctx, orgID.String())
+	if !profile.AllowedFor(plan) {
+		log.Printf("[Scan] Scan profile %s requires the %s plan, using %s for %s", profile.Name, profile.MinPlan, scanning.DefaultProfile, target)
+		return scanning.GetProfile(scanning.DefaultProfile)
+	}
+	return profile
+}
+
+// scanHost scans every resolved address of a host, so each backend of a load-balanced name is covered,
+// and records findings once per host no matter how many addresses share them
+func (o *Orchestrator) scanHost(ctx context.Context, state *scanState, portScanner *scanning.Scanner, host discovery.Result) {
+	servicesByIP := make(map[string][]*models.Service)
+	var pending []serviceFinding
+
+	seen := make(map[string]bool)
+	for _, ip := range host.IPs {
+		if seen[ip] || scanning.IPVersion(ip) == 0 {
+			continue
+		}
+		seen[ip] = true
+
+		services, findings := o.scanAddress(ctx, state, portScanner, host.Subdomain, ip)
+		servicesByIP[ip] = services
+		pending = append(pending, findings...)
+	}
+	pending = append(pending, ipv6OnlyFindings(state.hostname(host.Subdomain), servicesByIP)...)
+
+	o.recordHostFindings(ctx, state, host.Subdomain, pending)
+}
+
+// scanAddress port scans a single address of an asset, then fingerprints, classifies and probes each open port
+func (o *Orchestrator) scanAddress(ctx context.Context, state *scanState, portScanner *scanning.Scanner, subdomain string, ip string) ([]*models.Service, []serviceFinding) {
+	var scan tasks.PortScanResult
+	err := o.runTask(ctx, state.runID, state.orgID, tasks.KindPortScan, tasks.PortScanPayload{
+		IP:          ip,
+		Ports:       portScanner.PortsFor(ip),
+		TimeoutMS:   int(portScanner.Timeout / time.Millisecond),
+		Concurrency: portScanner.Concurrency,
+	}, &scan)
+	if err != nil {
+		log.Printf("[Scan] Port scan of %s failed: %v", ip, err)
+	}
+	ports := scan.Open
+	if state.rangeID != nil && len(ports) == 0 {
+		return nil, nil // Unused address in an IP range, not an asset
+	}
+
+	// Persistence: Save asset, annotated with reverse DNS and the network that hosts it
+	assetModel := &models.Asset{
+		DomainID:  state.domainID,
+		RangeID:   state.rangeID,
+		Subdomain: subdomain,
+		IPAddress: ip,
+		PTR:       state.resolver.ReverseLookup(ctx, ip),
+	}
+	if provider := o.Providers.Lookup(ip); provider != nil {
+		assetModel.Provider = provider.Name
+		assetModel.CDN = provider.CDN
+		if provider.CDN {
+			log.Printf("[Scan] %s is a %s edge address, limiting probes to CDN-proxied ports", ip, provider.Name)
+		}
+	}
+	o.Repo.SaveAsset(ctx, assetModel)
+
+	// Probe every open port; SNI names the virtual host unless we are sweeping bare addresses
+	var probed tasks.ProbeResult
+	if len(ports) > 0 {
+		sni := ""
+		if state.rangeID == nil {
+			sni = state.hostname(subdomain)
+		}
+		if err := o.runTask(ctx, state.runID, state.orgID, tasks.KindProbe, tasks.ProbePayload{IP: ip, ServerName: sni, Ports: ports}, &probed); err != nil {
+			log.Printf("[Scan] Probing %s failed: %v", ip, err)
+		}
+	}
+
+	var services []*models.Service
+	var findings []serviceFinding
+	for _, obs := range probed.Ports {
+		cert := obs.Certificate
+		fp := obs.HTTP
+		tech := obs.Technology
+
+		// Save service
+		serviceModel := &models.Service{
+			AssetID:  assetModel.ID,
+			Port:     obs.Port,
+			Protocol: obs.Protocol,
+		}
+
+		fpStr := ""
+		if fp != nil {
+			fpStr = fp.Text()
+			serviceModel.HTTPTitle = fp.Title
+			serviceModel.FaviconHash = fp.FaviconHash
+			serviceModel.ContentHash = fp.ContentHash
+			serviceModel.HTTPDetails, _ = json.Marshal(fp.Details())
+		}
+		serviceModel.Technology = string(tech)
+		serviceModel.Fingerprint = fpStr
+		for _, m := range obs.Technologies {
+			serviceModel.Technologies = append(serviceModel.Technologies, strings.TrimSpace(m.Name+" "+m.Version))
+		}
+		if len(obs.Technologies) > 0 {
+			top := obs.Technologies[0]
+			serviceModel.Product = top.Name
+			serviceModel.Version = top.Version
+			serviceModel.CPE = top.CPE
+		}
+
+		probe := obs.Service
+		if probe != nil {
+			serviceModel.Technology = probe.Service
+			serviceModel.Product = probe.Product
+			serviceModel.Version = probe.Version
+			serviceModel.CPE = probe.CPE
+			serviceModel.Unauthenticated = probe.Unauthenticated
+			if fpStr == "" {
+				serviceModel.Fingerprint = probe.Detail
+			}
+		} else if banner := obs.Banner; banner != nil {
+			if banner.Service != "" && tech == container.Unknown {
+				serviceModel.Technology = banner.Service
+			}
+			serviceModel.Product = banner.Product
+			serviceModel.Version = banner.Version
+			serviceModel.CPE = banner.CPE
+			serviceModel.Fingerprint = banner.Raw
+		}
+
+		if obs.Registry != nil && tech == container.Unknown {
+			serviceModel.Technology = string(container.Registry)
+		}
+		if k := obs.Kubernetes; k != nil {
+			serviceModel.Product = "Kubernetes API Server"
+			if k.Component != container.KubeAPIServer {
+				serviceModel.Product = "Kubernetes Kubelet"
+			}
+			if k.Version != "" {
+				serviceModel.Version = k.Version
+			}
+		}
+		ingress := obs.Ingress
+		if ingress != nil {
+			if tech == container.Unknown {
+				serviceModel.Technology = string(ingress.Controller)
+			}
+			if serviceModel.Product == "" || ingress.Version != "" {
+				serviceModel.Product = ingress.Name
+				serviceModel.Version = ingress.Version
+			}
+		}
+
+		tlsAssessment := obs.TLS
+		if tlsAssessment != nil {
+			serviceModel.TLSGrade = tlsAssessment.Grade
+			serviceModel.TLSVersions = tlsAssessment.Versions
+			serviceModel.WeakCiphers = tlsAssessment.WeakCiphers
+		}
+		o.Repo.SaveService(ctx, serviceModel)
+		services = append(services, serviceModel)
+
+		// Legacy protocols are only reported where they guard cluster access; elsewhere the grade reflects them
+		if tlsAssessment != nil && (tech == container.Kubernetes || ingress != nil || isIngress(fpStr, cert)) {
+			if legacy := tlsAssessment.LegacyVersions(); len(legacy) > 0 {
+				component, remediation := legacyTLSComponent(tech, obs.Kubernetes)
+				findings = append(findings, serviceFinding{
+					serviceID: serviceModel.ID,
+					exposure: risk.Exposure{
+						Type:        "Legacy TLS Protocol on " + component,
+						Severity:    risk.Medium,
+						Description: fmt.Sprintf("The %s on port %d accepts %s (TLS grade %s). These protocol versions are deprecated and vulnerable to downgrade and padding-oracle attacks against cluster credentials.", component, obs.Port, strings.Join(legacy, " and "), tlsAssessment.Grade),
+						Remediation: remediation,
+						AssetIP:     ip,
+						Port:        obs.Port,
+						Technology:  string(tech),
+					},
+				})
+			}
+		}
+
+		if ingress != nil {
+			findings = append(findings, issueFindings(serviceModel.ID, ip, obs.Port, ingress.Controller, ingress.Issues)...)
+		}
+		if registry := obs.Registry; registry != nil {
+			findings = append(findings, issueFindings(serviceModel.ID, ip, obs.Port, container.Registry, registry.Issues)...)
+		}
+
+		if k := obs.Kubernetes; k != nil {
+			findings = append(findings, issueFindings(serviceModel.ID, ip, obs.Port, container.Kubernetes, k.Issues)...)
+		}
+
+		if cert != nil {
+			o.saveCertificate(ctx, serviceModel.ID, cert)
+			state.addSANs(cert.SANs)
+			for _, issue := range cert.Issues(time.Now()) {
+				findings = append(findings, serviceFinding{
+					serviceID: serviceModel.ID,
+					exposure: risk.Exposure{
+						Type:        issue.Title,
+						Severity:    risk.Severity(issue.Severity),
+						Description: issue.Description,
+						Remediation: issue.Remediation,
+						AssetIP:     ip,
+						Port:        obs.Port,
+						Technology:  "tls",
+					},
+				})
+			}
+		}
+
+		if probe != nil {
+			exposure := risk.ClassifyService(probe)
+			exposure.AssetIP = ip
+			exposure.Port = obs.Port
+			exposure.Technology = probe.Service
+			findings = append(findings, serviceFinding{serviceID: serviceModel.ID, exposure: exposure})
+			continue
+		}
+		// The Kubernetes assessment replaces the generic classification once it reported something
+		if obs.Kubernetes != nil && len(obs.Kubernetes.Issues) > 0 {
+			continue
+		}
+
+		// Basic Classification
+		exposure := risk.Classify(obs.Port, tech, fpStr)
+
+		// Advanced Probing (Phase 3)
+		if adv := obs.Advanced; adv != nil {
+			exposure = risk.Exposure{
+				Type:        adv.Title,
+				Severity:    risk.Severity(adv.Severity),
+				Description: adv.Description,
+				Remediation: exposure.Remediation, // Fallback to basic remediation
+				AssetIP:     ip,
+				Port:        obs.Port,
+				Technology:  string(tech),
+			}
+			// Specific remediation for advanced probes
+			if adv.Title == "Exposed Docker Remote API (Unauthenticated)" {
+				exposure.Remediation = "Disable TCP access to the Docker API or enforce MTLS authentication using certificates."
+			}
+		} else {
+			// Add asset context to basic exposure
+			exposure.AssetIP = ip
+			exposure.Port = obs.Port
+			exposure.Technology = string(tech)
+		}
+
+		if exposure.Severity != risk.Info {
+			findings = append(findings, serviceFinding{serviceID: serviceModel.ID, exposure: exposure})
+		}
+	}
+	return services, findings
+}
+
+// issueFindings turns the issues an active probe reported into findings for a service
+func issueFindings(serviceID uuid.UUID, ip string, port int, tech container.Technology, issues []container.Issue) []serviceFinding {
+	var findings []serviceFinding
+	for _, issue := range issues {
+		findings = append(findings, serviceFinding{
+			serviceID: serviceID,
+			exposure: risk.Exposure{
+				Type:        issue.Title,
+				Severity:    risk.Severity(issue.Severity),
+				Description: issue.Description,
+				Remediation: issue.Remediation,
+				AssetIP:     ip,
+				Port:        port,
+				Technology:  string(tech),
+				Evidence:    issue.Evidence,
+			},
+		})
+	}
+	return findings
+}
+
+// legacyTLSComponent names the cluster component a legacy TLS finding is reported on, with the setting that fixes it
+func legacyTLSComponent(tech container.Technology, k *container.KubernetesInfo) (string, string) {
+	if tech == container.Kubernetes {
+		switch {
+		case k != nil && k.Component == container.KubeAPIServer:
+			return "Kubernetes API Server", "Set --tls-min-version=VersionTLS12 on the API server."
+		case k != nil:
+			return "Kubernetes Kubelet", "Set tlsMinVersion: VersionTLS12 in the KubeletConfiguration (--tls-min-version)."
+		}
+		return "Kubernetes Endpoint", "Set --tls-min-version=VersionTLS12 on the API server and kubelet."
+	}
+	return "Ingress Controller", "Set ssl-protocols (ingress-nginx), minVersion (Traefik TLSOption, Istio Gateway, Contour) or the equivalent to TLS 1.2 or later on the ingress controller."
+}
+
+// isIngress reports whether a web endpoint is served by a Kubernetes ingress controller,
+// recognized by its headers or the default certificate it falls back to
+func isIngress(fingerprint string, cert *discovery.CertificateInfo) bool {
+	fp := strings.ToLower(fingerprint)
+	for _, marker := range []string{"ingress", "traefik", "istio-envoy", "contour"} {
+		if strings.Contains(fp, marker) {
+			return true
+		}
+	}
+	return cert != nil && (strings.Contains(cert.Subject, "Kubernetes Ingress Controller Fake Certificate") ||
+		strings.Contains(cert.Subject, "TRAEFIK DEFAULT CERT"))
+}
+
+// saveCertificate persists the certificate a service presented
+func (o *Orchestrator) saveCertificate(ctx context.Context, serviceID uuid.UUID, cert *discovery.CertificateInfo) {
+	o.Repo.SaveCertificate(ctx, &models.Certificate{
+		ServiceID:          serviceID,
+		Subject:            cert.Subject,
+		Issuer:             cert.Issuer,
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		KeyType:            cert.KeyType,
+		KeyBits:            cert.KeyBits,
+		SignatureAlgorithm: cert.SignatureAlgorithm,
+		SANs:               cert.SANs,
+		SelfSigned:         cert.SelfSigned,
+		ChainComplete:      cert.ChainComplete,
+		ChainError:         cert.ChainError,
+		FingerprintSHA256:  cert.FingerprintSHA256,
+	})
+}
+
+// newSANHosts resolves certificate names under the scanned domain that are not yet known assets
+func (st *scanState) newSANHosts(ctx context.Context, known map[string]discovery.Result) []discovery.Result {
+	st.mu.Lock()
+	names := make([]string, 0, len(st.sans))
+	for name := range st.sans {
+		names = append(names, name)
+	}
+	st.mu.Unlock()
+
+	var hosts []discovery.Result
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimPrefix(name, "*.")
+		var subdomain string
+		switch {
+		case name == st.domainName:
+			subdomain = ""
+		case strings.HasSuffix(name, "."+st.domainName):
+			subdomain = strings.TrimSuffix(name, "."+st.domainName)
+		default:
+			continue // Other domains on a shared certificate are not in scope
+		}
+		if _, ok := known[subdomain]; ok || seen[subdomain] {
+			continue
+		}
+		seen[subdomain] = true
+
+		ips, err := outbound.LookupHost(ctx, name)
+		if err != nil || len(ips) == 0 {
+			continue
+		}
+		hosts = append(hosts, discovery.Result{Subdomain: subdomain, IPs: ips})
+	}
+	return hosts
+}
+
+// ipv6OnlyFindings flags ports of a dual-stack host that are reachable over IPv6 but not over IPv4,
+// which usually means the IPv6 firewall rules were never written
+func ipv6OnlyFindings(host string, servicesByIP map[string][]*models.Service) []serviceFinding {
+	v4Ports := make(map[int]bool)
+	var v4Addresses []string
+	for ip, services := range servicesByIP {
+		if scanning.IPVersion(ip) != 4 {
+			continue
+		}
+		v4Addresses = append(v4Addresses, ip)
+		for _, svc := range services {
+			v4Ports[svc.Port] = true
+		}
+	}
+	if len(v4Addresses) == 0 {
+		return nil // Not dual-stack
+	}
+
+	var findings []serviceFinding
+	for ip, services := range servicesByIP {
+		if scanning.IPVersion(ip) != 6 {
+			continue
+		}
+		for _, svc := range services {
+			if v4Ports[svc.Port] {
+				continue
+			}
+			findings = append(findings, serviceFinding{
+				serviceID: svc.ID,
+				exposure: risk.Exposure{
+					Type:        "Port Exposed Only Over IPv6",
+					Severity:    risk.Medium,
+					Description: fmt.Sprintf("Port %d on %s is reachable over IPv6 (%s) but not over IPv4 (%s). The IPv6 firewall is likely missing rules that exist for IPv4.", svc.Port, host, ip, strings.Join(v4Addresses, ", ")),
+					Remediation: "Mirror your IPv4 firewall or security group rules for IPv6, or disable IPv6 on the host if it is not needed.",
+					AssetIP:     ip,
+					Port:        svc.Port,
+					Technology:  svc.Technology,
+					Hostname:    host,
+				},
+			})
+		}
+	}
+	return findings
+}
+
+// recordHostFindings merges findings that repeat across the addresses of one host and records each once,
+// listing every affected address in the description
+func (o *Orchestrator) recordHostFindings(ctx context.Context, state *scanState, subdomain string, pending []serviceFinding) {
+	var order []string
+	merged := make(map[string]*serviceFinding)
+	addresses := make(map[string][]string)
+
+	for i := range pending {
+		f := pending[i]
+		key := fmt.Sprintf("%s-%s-%d", f.exposure.Type, f.exposure.Severity, f.exposure.Port)
+		if _, ok := merged[key]; !ok {
+			order = append(order, key)
+			merged[key] = &f
+		}
+		addresses[key] = append(addresses[key], f.exposure.AssetIP)
+	}
+
+	for _, key := range order {
+		f := merged[key]
+		f.exposure.Hostname = state.hostname(subdomain)
+		if ips := addresses[key]; len(ips) > 1 {
+			f.exposure.Description += fmt.Sprintf(" Affected addresses: %s.", strings.Join(ips, ", "))
+		}
+		o.recordServiceFinding(ctx, state, f.serviceID, f.exposure)
+	}
+}
+
+// recordServiceFinding persists a finding for a discovered service and tracks it for delta detection
+func (o *Orchestrator) recordServiceFinding(ctx context.Context, state *scanState, serviceID uuid.UUID, exposure risk.Exposure) {
+	state.track(exposure)
+
+	o.Repo.SaveFinding(ctx, &models.Finding{
+		ServiceID:   &serviceID,
+		DomainID:    state.findingDomainID(),
+		RangeID:     state.rangeID,
+		Target:      exposure.Hostname,
+		Type:        exposure.Type,
+		Severity:    string(exposure.Severity),
+		Description: exposure.Description,
+		Remediation: exposure.Remediation,
+		Evidence:    exposure.Evidence,
+	})
+}
+
+// recordDomainFinding persists a DNS-level finding that has no service and tracks it for delta detection
+func (o *Orchestrator) recordDomainFinding(ctx context.Context, state *scanState, exposure risk.Exposure) {
+	state.track(exposure)
+
+	o.Repo.SaveFinding(ctx, &models.Finding{
+		DomainID:    state.findingDomainID(),
+		RangeID:     state.rangeID,
+		Target:      exposure.Hostname,
+		Type:        exposure.Type,
+		Severity:    string(exposure.Severity),
+		Description: exposure.Description,
+		Remediation: exposure.Remediation,
+	})
+}
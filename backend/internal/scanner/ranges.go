@@ -0,0 +1,130 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"cortex-backend/internal/discovery"
+	"cortex-backend/internal/risk"
+	"cortex-backend/internal/scanning"
+	"cortex-backend/pkg/models"
+)
+
+// rangeScanWorkers bounds how many addresses of a range are port scanned at once
+const rangeScanWorkers = 16
+
+// RunRangeScan sweeps every address of a verified IP range through the same pipeline as domain assets,
+// using the range's scan profile and attributing assets and findings to the range
+func (o *Orchestrator) RunRangeScan(ctx context.Context, rangeID string) (*ScanResult, error) {
+	ipRange, _ := o.Repo.GetIPRangeByID(ctx, rangeID)
+	if ipRange == nil {
+		return nil, fmt.Errorf("ip range not found")
+	}
+	if !ipRange.Verified {
+		return nil, fmt.Errorf("ip range %s is not verified", ipRange.CIDR)
+	}
+
+	allowed, err := o.Repo.CheckQuota(ctx, ipRange.OrgID.String())
+	if err != nil || !allowed {
+		return nil, fmt.Errorf("daily scan quota exceeded for this organization")
+	}
+
+	ips, err := scanning.ExpandCIDR(ipRange.CIDR)
+	if err != nil {
+		return nil, err
+	}
+
+	o.Repo.CreateAuditLog(ctx, &models.AuditLog{
+		OrgID:    &ipRange.OrgID,
+		Action:   "SCAN_START",
+		Metadata: fmt.Sprintf(`{"range": "%s", "addresses": %d}`, ipRange.CIDR, len(ips)),
+	})
+
+	profile := o.scanProfile(ctx, ipRange.OrgID, ipRange.ScanProfile, ipRange.CIDR)
+	runID, _ := o.Repo.CreateRangeScanRun(ctx, rangeID, profile.Name)
+
+	previousFindings, _ := o.Repo.GetLatestFindingsForRange(ctx, rangeID)
+	prevMap := make(map[string]bool)
+	for _, pf := range previousFindings {
+		prevMap[fmt.Sprintf("%s-%s", pf.Type, pf.Severity)] = true
+	}
+
+	state := &scanState{
+		runID:      runID,
+		domainName: ipRange.CIDR,
+		rangeID:    &ipRange.ID,
+		prevMap:    prevMap,
+		resolver:   discovery.NewScanner(),
+		orgID:      ipRange.OrgID,
+	}
+
+	portScanner := scanning.NewScanner()
+	portScanner.ApplyProfile(profile)
+	portScanner.Providers = o.Providers
+
+	log.Printf("[Scan] Sweeping %d addresses of %s with the %s profile", len(ips), ipRange.CIDR, profile.Name)
+
+	var mu sync.Mutex
+	var live []discovery.Result
+	addresses := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < rangeScanWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range addresses {
+				services, findings := o.scanAddress(ctx, state, portScanner, "", ip)
+				if len(services) == 0 {
+					continue
+				}
+
+				mu.Lock()
+				live = append(live, discovery.Result{IPs: []string{ip}})
+				mu.Unlock()
+
+				// Addresses in a range have no DNS name, so findings are reported against the IP itself
+				for _, f := range findings {
+					f.exposure.Hostname = ip
+					o.recordServiceFinding(ctx, state, f.serviceID, f.exposure)
+				}
+			}
+		}()
+	}
+	for _, ip := range ips {
+		if ctx.Err() != nil {
+			break
+		}
+		addresses <- ip
+	}
+	close(addresses)
+	wg.Wait()
+
+	if len(state.allFindings) > 1 {
+		for _, path := range risk.AnalyzeAttackPaths(state.allFindings) {
+			log.Printf("[AttackPath] Path: %s (Risk: %s, Score: %d) - %s",
+				path.ID, path.CombinedRisk, path.Score, path.Description)
+		}
+	}
+
+	o.Repo.UpdateScanRunStatus(ctx, runID, "completed")
+
+	o.Repo.CreateAuditLog(ctx, &models.AuditLog{
+		OrgID:    &ipRange.OrgID,
+		Action:   "SCAN_COMPLETE",
+		Metadata: fmt.Sprintf(`{"range": "%s", "live_hosts": %d, "findings": %d}`, ipRange.CIDR, len(live), len(state.allFindings)),
+	})
+
+	if len(state.newFindings) > 0 {
+		if err := o.AlertHandler.SendAlert(ipRange.CIDR, state.newFindings); err != nil {
+			log.Printf("[Alert] Failed to send alert: %v", err)
+		}
+	}
+
+	return &ScanResult{
+		Assets:      live,
+		AllFindings: state.allFindings,
+		NewFindings: state.newFindings,
+	}, nil
+}
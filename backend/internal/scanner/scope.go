@@ -0,0 +1,70 @@
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"cortex-backend/internal/discovery"
+	"cortex-backend/pkg/models"
+)
+
+// applyScope narrows a discovered host to the addresses its domain's scope rules allow,
+// reporting false if nothing is left to probe
+func (o *Orchestrator) applyScope(ctx context.Context, state *scanState, host discovery.Result) (discovery.Result, bool) {
+	name := state.hostname(host.Subdomain)
+	if ok, reason := state.scope.AllowsHost(host.Subdomain); !ok {
+		o.recordExclusion(ctx, state, name, reason)
+		return host, false
+	}
+
+	var ips []string
+	for _, ip := range host.IPs {
+		if ok, reason := state.scope.AllowsIP(ip); !ok {
+			o.recordExclusion(ctx, state, name+" ("+ip+")", reason)
+			continue
+		}
+		ips = append(ips, ip)
+	}
+	host.IPs = ips
+	return host, len(ips) > 0
+}
+
+// scopedPorts removes excluded ports from the port scan list
+func (o *Orchestrator) scopedPorts(ctx context.Context, state *scanState, ports []int) []int {
+	var allowed []int
+	for _, port := range ports {
+		if ok, reason := state.scope.AllowsPort(port); !ok {
+			o.recordExclusion(ctx, state, state.domainName, reason)
+			continue
+		}
+		allowed = append(allowed, port)
+	}
+	return allowed
+}
+
+// recordExclusion writes a SCOPE_EXCLUDED audit entry the first time a target is skipped in a scan
+func (o *Orchestrator) recordExclusion(ctx context.Context, state *scanState, target, reason string) {
+	state.mu.Lock()
+	if state.excluded == nil {
+		state.excluded = make(map[string]bool)
+	}
+	seen := state.excluded[target+"|"+reason]
+	state.excluded[target+"|"+reason] = true
+	state.mu.Unlock()
+	if seen {
+		return
+	}
+
+	log.Printf("[Scan] Out of scope: %s (%s)", target, reason)
+	metadata, _ := json.Marshal(map[string]string{
+		"domain": state.domainName,
+		"target": target,
+		"reason": reason,
+	})
+	o.Repo.CreateAuditLog(ctx, &models.AuditLog{
+		OrgID:    &state.orgID,
+		Action:   "SCOPE_EXCLUDED",
+		Metadata: string(metadata),
+	})
+}
@@ -0,0 +1,57 @@
+package scanning
+
+import (
+	"fmt"
+	"net"
+)
+
+// IPVersion returns 4 or 6 for a textual IP address, or 0 if it cannot be parsed
+func IPVersion(ip string) int {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return 0
+	}
+	if parsed.To4() != nil {
+		return 4
+	}
+	return 6
+}
+
+// MaxRangeHosts bounds how many addresses a single registered range may expand to
+const MaxRangeHosts = 4096
+
+// ExpandCIDR lists the host addresses of a range, skipping the IPv4 network and broadcast addresses
+func ExpandCIDR(cidr string) ([]string, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	ones, bits := network.Mask.Size()
+	if bits-ones > 12 {
+		return nil, fmt.Errorf("range %s exceeds %d addresses", cidr, MaxRangeHosts)
+	}
+
+	var ips []string
+	ip := network.IP.Mask(network.Mask)
+	for ; network.Contains(ip); ip = nextIP(ip) {
+		ips = append(ips, ip.String())
+	}
+
+	if bits == 32 && len(ips) > 2 {
+		ips = ips[1 : len(ips)-1]
+	}
+	return ips, nil
+}
+
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
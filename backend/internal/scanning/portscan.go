@@ -2,30 +2,34 @@ package scanning
 
 import (
 	"context"
-	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"time"
+
+	"cortex-backend/internal/hosting"
+	"cortex-backend/internal/outbound"
 )
 
 // Scanner handles port scanning for an IP/Asset
 type Scanner struct {
 	TargetPorts []int
 	Timeout     time.Duration
+	Providers   *hosting.Database // Optional; CDN edge IPs are limited to CDNPorts
+	CDNPorts    []int
+	Concurrency int
 }
 
+// NewScanner returns a scanner configured with the default scan profile
 func NewScanner() *Scanner {
-	return &Scanner{
-		TargetPorts: []int{
-			80, 443,               // HTTP/HTTPS
-			2375, 2376,           // Docker API
-			6443, 8443,           // K8s API
-			10250, 10255,         // Kubelet
-			5000,                 // Registry
-			3000, 8080, 9000, 9090, // Dashboards / Monitoring
-		},
-		Timeout: 2 * time.Second,
+	s := &Scanner{
+		Timeout:     2 * time.Second,
+		Concurrency: 20, // Moderate concurrency
+		// A CDN edge only proxies web traffic; other ports belong to the CDN, not the customer
+		CDNPorts: []int{80, 443},
 	}
+	s.ApplyProfile(GetProfile(DefaultProfile))
+	return s
 }
 
 // OpenPort represents a found open service
@@ -34,15 +38,15 @@ type OpenPort struct {
 	Protocol string
 }
 
-// ScanPorts checks for open ports on an IP address
+// ScanPorts checks for open ports on an IPv4 or IPv6 address
 func (s *Scanner) ScanPorts(ctx context.Context, ip string) ([]OpenPort, error) {
 	var results []OpenPort
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
-	semaphore := make(chan struct{}, 20) // Moderate concurrency
+	semaphore := make(chan struct{}, s.Concurrency)
 
-	for _, port := range s.TargetPorts {
+	for _, port := range s.PortsFor(ip) {
 		wg.Add(1)
 		go func(port int) {
 			defer wg.Done()
@@ -53,8 +57,8 @@ func (s *Scanner) ScanPorts(ctx context.Context, ip string) ([]OpenPort, error)
 				defer func() { <-semaphore }()
 			}
 
-			address := fmt.Sprintf("%s:%d", ip, port)
-			conn, err := net.DialTimeout("tcp", address, s.Timeout)
+			address := net.JoinHostPort(ip, strconv.Itoa(port))
+			conn, err := outbound.Dial(ctx, "tcp", address, s.Timeout)
 			if err == nil {
 				conn.Close()
 				mu.Lock()
@@ -70,3 +74,22 @@ func (s *Scanner) ScanPorts(ctx context.Context, ip string) ([]OpenPort, error)
 	wg.Wait()
 	return results, nil
 }
+
+// PortsFor returns the ports worth probing on ip, de-prioritizing shared CDN edges
+func (s *Scanner) PortsFor(ip string) []int {
+	if !s.Providers.IsCDN(ip) {
+		return s.TargetPorts
+	}
+
+	allowed := make(map[int]bool)
+	for _, p := range s.CDNPorts {
+		allowed[p] = true
+	}
+	var ports []int
+	for _, p := range s.TargetPorts {
+		if allowed[p] {
+			ports = append(ports, p)
+		}
+	}
+	return ports
+}
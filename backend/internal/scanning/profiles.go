@@ -0,0 +1,142 @@
+package scanning
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultProfile is used when a domain has not selected a profile
+const DefaultProfile = "quick"
+
+// PlanTiers lists plan tiers from lowest to highest; a profile is available to its min_plan and above
+var PlanTiers = []string{"free", "pro", "enterprise"}
+
+//go:embed scan_profiles.json
+var defaultScanProfiles []byte
+
+// PortRange is an inclusive range of ports
+type PortRange struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// Profile is a named set of ports to scan
+type Profile struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	MinPlan     string      `json:"min_plan"`
+	Ports       []int       `json:"ports"`
+	Ranges      []PortRange `json:"ranges,omitempty"`
+	Concurrency int         `json:"concurrency,omitempty"` // Parallel connection attempts, default 20
+	Timeout     string      `json:"timeout,omitempty"`     // Per-port dial timeout, default 2s
+}
+
+var (
+	profiles     map[string]*Profile
+	profilesOnce sync.Once
+)
+
+// LoadProfiles parses a scan profile file
+func LoadProfiles(path string) (map[string]*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseProfiles(data)
+}
+
+func parseProfiles(data []byte) (map[string]*Profile, error) {
+	var list []*Profile
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("invalid scan profile file: %v", err)
+	}
+
+	set := make(map[string]*Profile)
+	for _, p := range list {
+		if p.Timeout != "" {
+			if _, err := time.ParseDuration(p.Timeout); err != nil {
+				return nil, fmt.Errorf("profile %s: invalid timeout %q", p.Name, p.Timeout)
+			}
+		}
+		set[p.Name] = p
+	}
+	if set[DefaultProfile] == nil {
+		return nil, fmt.Errorf("scan profile file must define %q", DefaultProfile)
+	}
+	return set, nil
+}
+
+// Profiles returns the scan profiles from SCAN_PROFILES_PATH if set, otherwise the embedded defaults
+func Profiles() map[string]*Profile {
+	profilesOnce.Do(func() {
+		if path := os.Getenv("SCAN_PROFILES_PATH"); path != "" {
+			set, err := LoadProfiles(path)
+			if err == nil {
+				profiles = set
+				return
+			}
+			log.Printf("[Scan] Failed to load scan profiles from %s: %v", path, err)
+		}
+		profiles, _ = parseProfiles(defaultScanProfiles)
+	})
+	return profiles
+}
+
+// GetProfile returns a profile by name, or nil if it does not exist
+func GetProfile(name string) *Profile {
+	return Profiles()[name]
+}
+
+// PortList expands the profile's ports and ranges into a sorted, de-duplicated list
+func (p *Profile) PortList() []int {
+	seen := make(map[int]bool)
+	for _, port := range p.Ports {
+		seen[port] = true
+	}
+	for _, r := range p.Ranges {
+		for port := r.From; port <= r.To; port++ {
+			seen[port] = true
+		}
+	}
+
+	ports := make([]int, 0, len(seen))
+	for port := range seen {
+		if port > 0 && port <= 65535 {
+			ports = append(ports, port)
+		}
+	}
+	sort.Ints(ports)
+	return ports
+}
+
+// AllowedFor reports whether an org on the given plan may use the profile
+func (p *Profile) AllowedFor(plan string) bool {
+	return planRank(plan) >= planRank(p.MinPlan)
+}
+
+// planRank orders plan tiers; unknown plans rank as the lowest tier
+func planRank(plan string) int {
+	for i, tier := range PlanTiers {
+		if tier == plan {
+			return i
+		}
+	}
+	return 0
+}
+
+// ApplyProfile configures the scanner to probe the profile's ports with its concurrency and timeout
+func (s *Scanner) ApplyProfile(p *Profile) {
+	s.TargetPorts = p.PortList()
+	if p.Concurrency > 0 {
+		s.Concurrency = p.Concurrency
+	}
+	if d, err := time.ParseDuration(p.Timeout); err == nil && d > 0 {
+		s.Timeout = d
+	}
+}
@@ -0,0 +1,202 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"cortex-backend/internal/discovery"
+	"cortex-backend/pkg/models"
+)
+
+// durationEnv reads a duration such as "168h" from the environment, falling back to def
+func durationEnv(name string, def time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(name)); err == nil && d > 0 {
+		return d
+	}
+	return def
+}
+
+// warningInterval is how often the owner is reminded during a grace period, however often the scheduler runs
+const warningInterval = 24 * time.Hour
+
+// warningDue reports whether a grace period warning should be sent, given when the last one was
+func warningDue(warnedAt *time.Time) bool {
+	return warnedAt == nil || time.Since(*warnedAt) >= warningInterval
+}
+
+// reverifyDomains re-checks ownership of verified domains that have not been checked within
+// REVERIFY_INTERVAL (default 7 days). A failed check starts a REVERIFY_GRACE_PERIOD (default 7 days)
+// with at most one warning a day; if ownership is still unconfirmed afterwards, the domain is suspended.
+func (s *Scheduler) reverifyDomains(ctx context.Context) {
+	interval := durationEnv("REVERIFY_INTERVAL", 7*24*time.Hour)
+	grace := durationEnv("REVERIFY_GRACE_PERIOD", 7*24*time.Hour)
+
+	domains, err := s.Repo.GetAllVerifiedDomains(ctx)
+	if err != nil {
+		log.Printf("Scheduler error: failed to fetch domains for re-verification: %v", err)
+		return
+	}
+
+	for _, d := range domains {
+		// Domains inside their grace period are re-checked on every run so a restored record is noticed quickly
+		if d.VerificationFailedAt == nil && d.VerificationCheckedAt != nil && time.Since(*d.VerificationCheckedAt) < interval {
+			continue
+		}
+
+		if s.ownershipConfirmed(ctx, d) {
+			s.Repo.MarkDomainReverified(ctx, d.ID.String())
+			if d.VerificationFailedAt != nil {
+				log.Printf("Ownership of %s re-verified", d.RootDomain)
+				s.audit(ctx, d, "DOMAIN_REVERIFIED", nil)
+			}
+			continue
+		}
+
+		failedSince, warnedAt, err := s.Repo.MarkDomainReverifyFailed(ctx, d.ID.String())
+		if err != nil {
+			log.Printf("Scheduler error: failed to record re-verification failure for %s: %v", d.RootDomain, err)
+			continue
+		}
+		graceEnds := failedSince.Add(grace)
+
+		if time.Now().Before(graceEnds) {
+			log.Printf("Ownership of %s could not be re-verified; grace period ends %s", d.RootDomain, graceEnds.Format(time.RFC3339))
+			s.audit(ctx, d, "DOMAIN_REVERIFY_FAILED", map[string]interface{}{"grace_ends": graceEnds})
+			if warningDue(warnedAt) {
+				s.Orchestrator.AlertHandler.SendVerificationWarning(d.RootDomain, graceEnds, false)
+				s.Repo.MarkDomainWarned(ctx, d.ID.String())
+			}
+			continue
+		}
+
+		log.Printf("Ownership of %s unconfirmed since %s; suspending scheduled scans", d.RootDomain, failedSince.Format(time.RFC3339))
+		if err := s.Repo.SuspendDomain(ctx, d.ID.String()); err != nil {
+			log.Printf("Scheduler error: failed to suspend %s: %v", d.RootDomain, err)
+			continue
+		}
+		s.audit(ctx, d, "DOMAIN_SCANS_SUSPENDED", map[string]interface{}{"failed_since": failedSince})
+		s.Orchestrator.AlertHandler.SendVerificationWarning(d.RootDomain, graceEnds, true)
+	}
+}
+
+// reverifyRanges re-checks the reverse DNS records of verified IP ranges on the same schedule and
+// grace period as domains; a range whose ownership stays unconfirmed is suspended
+func (s *Scheduler) reverifyRanges(ctx context.Context) {
+	interval := durationEnv("REVERIFY_INTERVAL", 7*24*time.Hour)
+	grace := durationEnv("REVERIFY_GRACE_PERIOD", 7*24*time.Hour)
+
+	ranges, err := s.Repo.GetAllVerifiedIPRanges(ctx)
+	if err != nil {
+		log.Printf("Scheduler error: failed to fetch IP ranges for re-verification: %v", err)
+		return
+	}
+
+	for _, ir := range ranges {
+		if ir.VerificationFailedAt == nil && ir.VerificationCheckedAt != nil && time.Since(*ir.VerificationCheckedAt) < interval {
+			continue
+		}
+
+		if missing, err := discovery.VerifyIPRange(ir.CIDR, ir.VerificationToken); err == nil && len(missing) == 0 {
+			s.Repo.MarkIPRangeReverified(ctx, ir.ID.String())
+			if ir.VerificationFailedAt != nil {
+				log.Printf("Ownership of %s re-verified", ir.CIDR)
+				s.auditRange(ctx, ir, "RANGE_REVERIFIED", nil)
+			}
+			continue
+		}
+
+		failedSince, warnedAt, err := s.Repo.MarkIPRangeReverifyFailed(ctx, ir.ID.String())
+		if err != nil {
+			log.Printf("Scheduler error: failed to record re-verification failure for %s: %v", ir.CIDR, err)
+			continue
+		}
+		graceEnds := failedSince.Add(grace)
+
+		if time.Now().Before(graceEnds) {
+			log.Printf("Ownership of %s could not be re-verified; grace period ends %s", ir.CIDR, graceEnds.Format(time.RFC3339))
+			s.auditRange(ctx, ir, "RANGE_REVERIFY_FAILED", map[string]interface{}{"grace_ends": graceEnds})
+			if warningDue(warnedAt) {
+				s.Orchestrator.AlertHandler.SendVerificationWarning(ir.CIDR, graceEnds, false)
+				s.Repo.MarkIPRangeWarned(ctx, ir.ID.String())
+			}
+			continue
+		}
+
+		log.Printf("Ownership of %s unconfirmed since %s; suspending scheduled scans", ir.CIDR, failedSince.Format(time.RFC3339))
+		if err := s.Repo.SuspendIPRange(ctx, ir.ID.String()); err != nil {
+			log.Printf("Scheduler error: failed to suspend %s: %v", ir.CIDR, err)
+			continue
+		}
+		s.auditRange(ctx, ir, "RANGE_SCANS_SUSPENDED", map[string]interface{}{"failed_since": failedSince})
+		s.Orchestrator.AlertHandler.SendVerificationWarning(ir.CIDR, graceEnds, true)
+	}
+}
+
+// ownershipConfirmed repeats the verification method that originally proved ownership,
+// trying every method for domains verified before methods were recorded
+func (s *Scheduler) ownershipConfirmed(ctx context.Context, d models.Domain) bool {
+	if d.VerificationMethod == discovery.MethodParentDomain {
+		return s.parentVerified(ctx, d)
+	}
+
+	methods := discovery.VerificationMethods
+	if d.VerificationMethod != "" {
+		methods = []string{d.VerificationMethod}
+	}
+	for _, method := range methods {
+		if ok, _ := discovery.CheckVerification(method, d.RootDomain, d.VerificationToken); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// parentVerified reports whether a delegating parent domain of the same org is still verified
+func (s *Scheduler) parentVerified(ctx context.Context, d models.Domain) bool {
+	parents, err := s.Repo.GetVerifiedDomains(ctx, d.OrgID.String())
+	if err != nil {
+		return false
+	}
+	for _, p := range parents {
+		if strings.HasSuffix(d.RootDomain, "."+p.RootDomain) && p.VerificationFailedAt == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scheduler) audit(ctx context.Context, d models.Domain, action string, extra map[string]interface{}) {
+	metadata := map[string]interface{}{
+		"domain":    d.RootDomain,
+		"domain_id": d.ID.String(),
+	}
+	for k, v := range extra {
+		metadata[k] = v
+	}
+	metadataJSON, _ := json.Marshal(metadata)
+	s.Repo.CreateAuditLog(ctx, &models.AuditLog{
+		OrgID:    &d.OrgID,
+		Action:   action,
+		Metadata: string(metadataJSON),
+	})
+}
+
+func (s *Scheduler) auditRange(ctx context.Context, ir models.IPRange, action string, extra map[string]interface{}) {
+	metadata := map[string]interface{}{
+		"cidr":     ir.CIDR,
+		"range_id": ir.ID.String(),
+	}
+	for k, v := range extra {
+		metadata[k] = v
+	}
+	metadataJSON, _ := json.Marshal(metadata)
+	s.Repo.CreateAuditLog(ctx, &models.AuditLog{
+		OrgID:    &ir.OrgID,
+		Action:   action,
+		Metadata: string(metadataJSON),
+	})
+}
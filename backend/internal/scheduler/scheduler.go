@@ -45,6 +45,9 @@ func (s *Scheduler) Start(ctx context.Context) {
 }
 
 func (s *Scheduler) runPendingScans(ctx context.Context) {
+	// Ownership is re-confirmed first so domains that lost it are not scanned in this run
+	s.reverifyDomains(ctx)
+
 	log.Println("Checking for pending scans for verified domains...")
 	
 	domains, err := s.Repo.GetAllVerifiedDomains(ctx)
@@ -64,4 +67,23 @@ func (s *Scheduler) runPendingScans(ctx context.Context) {
 			}
 		}(d)
 	}
+
+	s.reverifyRanges(ctx)
+	ranges, err := s.Repo.GetAllVerifiedIPRanges(ctx)
+	if err != nil {
+		log.Printf("Scheduler error: failed to fetch verified IP ranges: %v", err)
+		return
+	}
+
+	for _, ir := range ranges {
+		log.Printf("Triggering automated scan for range: %s", ir.CIDR)
+		go func(ipRange models.IPRange) {
+			_, scanErr := s.Orchestrator.RunRangeScan(ctx, ipRange.ID.String())
+			if scanErr != nil {
+				log.Printf("Automated scan failed for %s: %v", ipRange.CIDR, scanErr)
+			} else {
+				log.Printf("Automated scan completed for %s", ipRange.CIDR)
+			}
+		}(ir)
+	}
 }
@@ -0,0 +1,174 @@
+package scope
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cortex-backend/pkg/models"
+)
+
+// Rule types stored in scope_rules.rule_type
+const (
+	IncludeSubdomain = "include_subdomain"
+	ExcludeSubdomain = "exclude_subdomain"
+	ExcludeCIDR      = "exclude_cidr"
+	ExcludePort      = "exclude_port"
+)
+
+// Policy is the compiled set of scope rules of a domain. Subdomain includes win over subdomain
+// excludes; CIDR and port exclusions always apply, since they usually protect third-party infrastructure.
+type Policy struct {
+	includes      []pattern
+	excludes      []pattern
+	excludedNets  []*net.IPNet
+	excludedPorts map[int]bool
+}
+
+type pattern struct {
+	raw string
+	re  *regexp.Regexp
+}
+
+// Compile parses a domain's rules into a policy, failing on the first invalid rule
+func Compile(rules []models.ScopeRule) (*Policy, error) {
+	p := &Policy{excludedPorts: make(map[int]bool)}
+	for _, rule := range rules {
+		if err := p.add(rule.RuleType, rule.Pattern); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// ValidateRule checks that a rule type and pattern can be compiled
+func ValidateRule(ruleType, value string) error {
+	return (&Policy{excludedPorts: make(map[int]bool)}).add(ruleType, value)
+}
+
+func (p *Policy) add(ruleType, value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fmt.Errorf("pattern is required")
+	}
+
+	switch ruleType {
+	case IncludeSubdomain, ExcludeSubdomain:
+		pat, err := compilePattern(value)
+		if err != nil {
+			return err
+		}
+		if ruleType == IncludeSubdomain {
+			p.includes = append(p.includes, pat)
+		} else {
+			p.excludes = append(p.excludes, pat)
+		}
+	case ExcludeCIDR:
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR %q", value)
+		}
+		p.excludedNets = append(p.excludedNets, network)
+	case ExcludePort:
+		port, err := strconv.Atoi(value)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %q", value)
+		}
+		p.excludedPorts[port] = true
+	default:
+		return fmt.Errorf("unknown rule type %q", ruleType)
+	}
+	return nil
+}
+
+// compilePattern accepts a glob (e.g. "*.internal-apps", "mail") or a regex prefixed with "re:" (e.g. "re:^dev-[0-9]+$").
+// Patterns are matched case-insensitively against the subdomain label relative to the root domain.
+func compilePattern(value string) (pattern, error) {
+	if strings.HasPrefix(value, "re:") {
+		re, err := regexp.Compile("(?i)" + strings.TrimPrefix(value, "re:"))
+		if err != nil {
+			return pattern{}, fmt.Errorf("invalid regex %q: %v", value, err)
+		}
+		return pattern{raw: value, re: re}, nil
+	}
+
+	glob := strings.ToLower(strings.Trim(value, "."))
+	var b strings.Builder
+	b.WriteString("^")
+	for _, c := range glob {
+		switch c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return pattern{raw: value, re: regexp.MustCompile(b.String())}, nil
+}
+
+// AllowsHost reports whether a subdomain ("" for the apex) may be probed, and if not, which rule excludes it
+func (p *Policy) AllowsHost(subdomain string) (bool, string) {
+	if p == nil {
+		return true, ""
+	}
+	subdomain = strings.ToLower(subdomain)
+	for _, inc := range p.includes {
+		if inc.re.MatchString(subdomain) {
+			return true, ""
+		}
+	}
+	for _, exc := range p.excludes {
+		if exc.re.MatchString(subdomain) {
+			return false, fmt.Sprintf("subdomain matches exclusion %q", exc.raw)
+		}
+	}
+	return true, ""
+}
+
+// AllowsIP reports whether an address may be probed, and if not, which excluded range contains it
+func (p *Policy) AllowsIP(ip string) (bool, string) {
+	parsed := net.ParseIP(ip)
+	if p == nil || parsed == nil {
+		return true, ""
+	}
+	for _, network := range p.excludedNets {
+		if network.Contains(parsed) {
+			return false, fmt.Sprintf("address is in excluded range %s", network.String())
+		}
+	}
+	return true, ""
+}
+
+// AllowsPort reports whether a port may be probed
+func (p *Policy) AllowsPort(port int) (bool, string) {
+	if p == nil || !p.excludedPorts[port] {
+		return true, ""
+	}
+	return false, fmt.Sprintf("port %d is excluded", port)
+}
+
+// ExplicitHosts returns include patterns that name a single subdomain, so discovery can resolve them directly
+func (p *Policy) ExplicitHosts() []string {
+	if p == nil {
+		return nil
+	}
+	var hosts []string
+	for _, inc := range p.includes {
+		if !strings.HasPrefix(inc.raw, "re:") && !strings.ContainsAny(inc.raw, "*?") {
+			hosts = append(hosts, strings.ToLower(strings.Trim(inc.raw, ".")))
+		}
+	}
+	return hosts
+}
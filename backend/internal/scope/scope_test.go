@@ -0,0 +1,166 @@
+package scope
+
+import (
+	"testing"
+
+	"cortex-backend/pkg/models"
+)
+
+func compile(t *testing.T, rules ...models.ScopeRule) *Policy {
+	t.Helper()
+	p, err := Compile(rules)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	return p
+}
+
+func rule(ruleType, pattern string) models.ScopeRule {
+	return models.ScopeRule{RuleType: ruleType, Pattern: pattern}
+}
+
+func TestCompileRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		ruleType string
+		pattern  string
+	}{
+		{ExcludeSubdomain, ""},
+		{ExcludeSubdomain, "   "},
+		{ExcludeSubdomain, "re:(unclosed"},
+		{ExcludeCIDR, "10.0.0.0/33"},
+		{ExcludeCIDR, "not-an-address"},
+		{ExcludePort, "0"},
+		{ExcludePort, "65536"},
+		{ExcludePort, "http"},
+		{"exclude_everything", "*"},
+	}
+	for _, tt := range tests {
+		if _, err := Compile([]models.ScopeRule{rule(tt.ruleType, tt.pattern)}); err == nil {
+			t.Errorf("Compile(%s %q) succeeded, want error", tt.ruleType, tt.pattern)
+		}
+		if err := ValidateRule(tt.ruleType, tt.pattern); err == nil {
+			t.Errorf("ValidateRule(%s %q) succeeded, want error", tt.ruleType, tt.pattern)
+		}
+	}
+}
+
+func TestAllowsHost(t *testing.T) {
+	p := compile(t,
+		rule(ExcludeSubdomain, "*.internal-apps"),
+		rule(ExcludeSubdomain, "Mail"),
+		rule(ExcludeSubdomain, "dev-?"),
+		rule(ExcludeSubdomain, "re:^staging-[0-9]+$"),
+		rule(ExcludeSubdomain, "*.corp"),
+		// Includes win over excludes
+		rule(IncludeSubdomain, "public.corp"),
+		rule(IncludeSubdomain, "re:^staging-1$"),
+	)
+
+	tests := []struct {
+		subdomain string
+		allowed   bool
+	}{
+		{"", true},
+		{"www", true},
+		{"a.internal-apps", false},
+		{"a.b.internal-apps", false},
+		{"internal-apps", true}, // The glob needs a label before the dot
+		{"mail", false},
+		{"MAIL", false},
+		{"mail2", true}, // Globs are anchored
+		{"webmail", true},
+		{"dev-a", false},
+		{"dev-ab", true},
+		{"staging-7", false},
+		{"staging-x", true},
+		{"staging-1", true},
+		{"hr.corp", false},
+		{"public.corp", true},
+		{"Public.Corp", true},
+	}
+	for _, tt := range tests {
+		ok, reason := p.AllowsHost(tt.subdomain)
+		if ok != tt.allowed {
+			t.Errorf("AllowsHost(%q) = %v (%s), want %v", tt.subdomain, ok, reason, tt.allowed)
+		}
+		if !ok && reason == "" {
+			t.Errorf("AllowsHost(%q) excluded without a reason", tt.subdomain)
+		}
+	}
+}
+
+func TestAllowsIP(t *testing.T) {
+	p := compile(t,
+		rule(ExcludeCIDR, "10.0.0.0/8"),
+		rule(ExcludeCIDR, "192.0.2.7"),
+		rule(ExcludeCIDR, "2001:db8::/32"),
+		rule(ExcludeCIDR, "2001:db9::1"),
+		// Subdomain includes do not lift address exclusions
+		rule(IncludeSubdomain, "*"),
+	)
+
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"10.1.2.3", false},
+		{"11.0.0.1", true},
+		{"192.0.2.7", false},
+		{"192.0.2.8", true},
+		{"2001:db8::1", false},
+		{"2001:db9::1", false},
+		{"2001:db9::2", true},
+		{"::ffff:10.0.0.1", false}, // IPv4-mapped addresses are the same host
+		{"not-an-ip", true},
+	}
+	for _, tt := range tests {
+		if ok, reason := p.AllowsIP(tt.ip); ok != tt.allowed {
+			t.Errorf("AllowsIP(%q) = %v (%s), want %v", tt.ip, ok, reason, tt.allowed)
+		}
+	}
+}
+
+func TestAllowsPort(t *testing.T) {
+	p := compile(t, rule(ExcludePort, "22"), rule(ExcludePort, " 3389 "), rule(IncludeSubdomain, "*"))
+	for port, allowed := range map[int]bool{22: false, 3389: false, 80: true, 443: true} {
+		if ok, _ := p.AllowsPort(port); ok != allowed {
+			t.Errorf("AllowsPort(%d) = %v, want %v", port, ok, allowed)
+		}
+	}
+}
+
+func TestNilPolicyAllowsEverything(t *testing.T) {
+	var p *Policy
+	if ok, _ := p.AllowsHost("anything"); !ok {
+		t.Error("nil policy excluded a host")
+	}
+	if ok, _ := p.AllowsIP("10.0.0.1"); !ok {
+		t.Error("nil policy excluded an address")
+	}
+	if ok, _ := p.AllowsPort(22); !ok {
+		t.Error("nil policy excluded a port")
+	}
+	if hosts := p.ExplicitHosts(); hosts != nil {
+		t.Errorf("nil policy has explicit hosts %v", hosts)
+	}
+}
+
+func TestExplicitHosts(t *testing.T) {
+	p := compile(t,
+		rule(IncludeSubdomain, "VPN."),
+		rule(IncludeSubdomain, "*.apps"),
+		rule(IncludeSubdomain, "re:^api$"),
+		rule(IncludeSubdomain, "build"),
+		rule(ExcludeSubdomain, "legacy"),
+	)
+	got := p.ExplicitHosts()
+	want := []string{"vpn", "build"}
+	if len(got) != len(want) {
+		t.Fatalf("ExplicitHosts() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ExplicitHosts() = %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	neturl "net/url"
+	"strconv"
+	"time"
+
+	"cortex-backend/internal/container"
+	"cortex-backend/internal/discovery"
+	"cortex-backend/internal/fingerprinting"
+	"cortex-backend/internal/scanning"
+)
+
+// Execute runs a task on this machine and returns its JSON result
+func Execute(ctx context.Context, task Task) (json.RawMessage, error) {
+	var result interface{}
+	switch task.Kind {
+	case KindDiscovery:
+		var p DiscoveryPayload
+		if err := json.Unmarshal(task.Payload, &p); err != nil {
+			return nil, fmt.Errorf("invalid %s payload: %v", task.Kind, err)
+		}
+		result = Discover(ctx, p)
+	case KindPortScan:
+		var p PortScanPayload
+		if err := json.Unmarshal(task.Payload, &p); err != nil {
+			return nil, fmt.Errorf("invalid %s payload: %v", task.Kind, err)
+		}
+		result = PortScan(ctx, p)
+	case KindProbe:
+		var p ProbePayload
+		if err := json.Unmarshal(task.Payload, &p); err != nil {
+			return nil, fmt.Errorf("invalid %s payload: %v", task.Kind, err)
+		}
+		result = Probe(ctx, p)
+	default:
+		return nil, fmt.Errorf("unknown task kind %q", task.Kind)
+	}
+	return json.Marshal(result)
+}
+
+// Discover brute-forces common subdomains and resolves the explicitly requested names
+func Discover(ctx context.Context, p DiscoveryPayload) DiscoveryResult {
+	dnsScanner := discovery.NewScanner()
+	assets, _ := dnsScanner.EnumerateSubdomains(ctx, p.Domain)
+	assets = append(assets, dnsScanner.ResolveSubdomains(ctx, p.Domain, p.Hosts)...)
+	return DiscoveryResult{Assets: assets}
+}
+
+// PortScan connect-scans the requested ports
+func PortScan(ctx context.Context, p PortScanPayload) PortScanResult {
+	portScanner := scanning.NewScanner()
+	portScanner.TargetPorts = p.Ports
+	if p.TimeoutMS > 0 {
+		portScanner.Timeout = time.Duration(p.TimeoutMS) * time.Millisecond
+	}
+	if p.Concurrency > 0 {
+		portScanner.Concurrency = p.Concurrency
+	}
+	open, _ := portScanner.ScanPorts(ctx, p.IP)
+	return PortScanResult{Open: open}
+}
+
+// Probe inspects every open port: TLS certificate and protocol support, HTTP fingerprint,
+// native protocol handshakes, banners and container API access
+func Probe(ctx context.Context, p ProbePayload) ProbeResult {
+	var result ProbeResult
+	for _, port := range p.Ports {
+		result.Ports = append(result.Ports, probePort(ctx, p.IP, p.ServerName, port))
+	}
+	return result
+}
+
+func probePort(ctx context.Context, ip, sni string, p scanning.OpenPort) PortObservation {
+	obs := PortObservation{Port: p.Port, Protocol: p.Protocol}
+
+	// TLS Inspection - any port may speak TLS; its certificate also decides the URL scheme
+	cert, _ := discovery.InspectTLS(ctx, ip, p.Port, sni)
+	obs.Certificate = cert
+
+	url := "http://"
+	if cert != nil || p.Port == 443 || p.Port == 2376 || p.Port == 6443 {
+		url = "https://"
+	}
+	url += net.JoinHostPort(ip, strconv.Itoa(p.Port))
+
+	fp, _ := fingerprinting.HTTPFingerprint(ctx, url)
+	obs.HTTP = fp
+	obs.Technologies = container.Identify(p.Port, fp)
+	obs.Technology = container.Primary(obs.Technologies)
+	if fp != nil {
+		// Use the scheme that answered, which may differ after redirects or the HTTPS retry, but never another
+		// address or port: results are reported under this port, and another port may be out of scope
+		if base, ok := probeBase(fp.URL, ip, p.Port); ok {
+			obs.Ingress = container.ProbeIngress(ctx, base, p.Port, obs.Technologies, fp)
+			if isRegistry(p.Port, obs.Technologies) {
+				obs.Registry = container.ProbeRegistry(ctx, base, p.Port)
+			}
+		}
+	}
+
+	// Protocol probes speak the native handshake of data stores that ignore HTTP
+	obs.Service = fingerprinting.ProbeService(ctx, ip, p.Port)
+	if obs.Service == nil && fp == nil && cert == nil {
+		// Neither HTTP nor TLS: identify the service from the banner it announces itself with
+		obs.Banner = fingerprinting.GrabBanner(ctx, ip, p.Port)
+	}
+
+	if cert != nil {
+		obs.TLS = discovery.AssessTLS(ctx, ip, p.Port, sni)
+	}
+
+	// The API server and kubelet are assessed on their own; they may not have answered the fingerprint request
+	if obs.Technology == container.Kubernetes {
+		obs.Kubernetes = container.ProbeKubernetes(ctx, ip, p.Port)
+	}
+
+	if sev, title, desc := container.ProbeAdvanced(ctx, ip, p.Port, obs.Technology); sev != "" {
+		obs.Advanced = &AdvancedProbe{Severity: sev, Title: title, Description: desc}
+	}
+	return obs
+}
+
+// probeBase returns the scheme of the fingerprinted URL joined with ip:port, or false if the
+// fingerprint ended on another address or port
+func probeBase(fpURL, ip string, port int) (string, bool) {
+	u, err := neturl.Parse(fpURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", false
+	}
+	urlPort := u.Port()
+	if urlPort == "" {
+		urlPort = "80"
+		if u.Scheme == "https" {
+			urlPort = "443"
+		}
+	}
+	if net.ParseIP(u.Hostname()).String() != net.ParseIP(ip).String() || urlPort != strconv.Itoa(port) {
+		return "", false
+	}
+	return u.Scheme + "://" + net.JoinHostPort(ip, urlPort), true
+}
+
+// isRegistry reports whether a port is worth probing for the registry API
+func isRegistry(port int, matches []container.Match) bool {
+	for _, m := range matches {
+		if m.Technology == container.Registry {
+			return true
+		}
+	}
+	return port == 5000
+}
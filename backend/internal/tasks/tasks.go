@@ -0,0 +1,89 @@
+// Package tasks defines the network-facing units of scan work. The orchestrator hands them to
+// remote agents when an org has any online, and otherwise executes them in-process; persistence
+// and risk classification always stay with the orchestrator.
+package tasks
+
+import (
+	"encoding/json"
+
+	"cortex-backend/internal/container"
+	"cortex-backend/internal/discovery"
+	"cortex-backend/internal/fingerprinting"
+	"cortex-backend/internal/scanning"
+	"github.com/google/uuid"
+)
+
+// Kinds of task
+const (
+	KindDiscovery = "discovery"
+	KindPortScan  = "port_scan"
+	KindProbe     = "probe"
+)
+
+// Task is a unit of work handed to an agent; Payload holds the kind's payload document
+type Task struct {
+	ID      uuid.UUID       `json:"id"`
+	Kind    string          `json:"kind"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// DiscoveryPayload asks for active subdomain enumeration of a domain
+type DiscoveryPayload struct {
+	Domain string   `json:"domain"`
+	Hosts  []string `json:"hosts,omitempty"` // Explicitly included names resolved even if no wordlist contains them
+}
+
+// DiscoveryResult lists the names that resolved
+type DiscoveryResult struct {
+	Assets []discovery.Result `json:"assets"`
+}
+
+// PortScanPayload asks for a TCP connect scan of one address. Ports are already limited
+// to the scan profile, scope rules and CDN restrictions.
+type PortScanPayload struct {
+	IP          string `json:"ip"`
+	Ports       []int  `json:"ports"`
+	TimeoutMS   int    `json:"timeoutMs"`
+	Concurrency int    `json:"concurrency"`
+}
+
+// PortScanResult lists the open ports
+type PortScanResult struct {
+	Open []scanning.OpenPort `json:"open"`
+}
+
+// ProbePayload asks for TLS, HTTP, protocol and banner probes of open ports of one address
+type ProbePayload struct {
+	IP         string              `json:"ip"`
+	ServerName string              `json:"serverName,omitempty"` // SNI; empty for bare addresses
+	Ports      []scanning.OpenPort `json:"ports"`
+}
+
+// ProbeResult holds one observation per probed port
+type ProbeResult struct {
+	Ports []PortObservation `json:"ports"`
+}
+
+// PortObservation is everything the probes learned about one open port
+type PortObservation struct {
+	Port         int                         `json:"port"`
+	Protocol     string                      `json:"protocol"`
+	Certificate  *discovery.CertificateInfo  `json:"certificate,omitempty"`
+	TLS          *discovery.TLSAssessment    `json:"tls,omitempty"`
+	HTTP         *fingerprinting.Fingerprint `json:"http,omitempty"`
+	Service      *fingerprinting.ServiceInfo `json:"service,omitempty"`
+	Banner       *fingerprinting.Banner      `json:"banner,omitempty"`
+	Technology   container.Technology        `json:"technology"`
+	Technologies []container.Match           `json:"technologies,omitempty"` // Most confident first
+	Ingress      *container.IngressInfo      `json:"ingress,omitempty"`
+	Registry     *container.RegistryInfo     `json:"registry,omitempty"`
+	Kubernetes   *container.KubernetesInfo   `json:"kubernetes,omitempty"`
+	Advanced     *AdvancedProbe              `json:"advanced,omitempty"`
+}
+
+// AdvancedProbe is the outcome of a container-specific access check
+type AdvancedProbe struct {
+	Severity    string `json:"severity"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
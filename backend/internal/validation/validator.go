@@ -2,6 +2,7 @@ package validation
 
 import (
 	"errors"
+	"net"
 	"regexp"
 	"strings"
 	"unicode"
@@ -33,6 +34,57 @@ func ValidateDomain(domain string) error {
 	return nil
 }
 
+// ValidateCIDR validates an IP range and keeps it small enough to scan (at most 4096 addresses)
+func ValidateCIDR(cidr string) error {
+	if cidr == "" {
+		return errors.New("cidr is required")
+	}
+
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return errors.New("invalid CIDR format")
+	}
+
+	if !ip.Equal(network.IP) {
+		return errors.New("CIDR must use the network address (e.g. 203.0.113.0/24)")
+	}
+
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() || ip.IsMulticast() {
+		return errors.New("only public IP ranges can be registered")
+	}
+
+	ones, bits := network.Mask.Size()
+	if bits-ones > 12 {
+		return errors.New("range too large (maximum 4096 addresses, e.g. /20 for IPv4 or /116 for IPv6)")
+	}
+
+	return nil
+}
+
+// ValidateASN validates an autonomous system number such as AS64500
+func ValidateASN(asn string) error {
+	matched, _ := regexp.MatchString(`^(?i)AS[0-9]{1,10}$`, asn)
+	if !matched {
+		return errors.New("invalid ASN format (expected e.g. AS64500)")
+	}
+	return nil
+}
+
+// ValidateAgentName validates the display name of a scan agent
+func ValidateAgentName(name string) error {
+	if name == "" {
+		return errors.New("agent name is required")
+	}
+	if len(name) > 64 {
+		return errors.New("agent name too long (maximum 64 characters)")
+	}
+	matched, _ := regexp.MatchString(`^[a-zA-Z0-9][a-zA-Z0-9 ._\-]*$`, name)
+	if !matched {
+		return errors.New("agent name can only contain letters, numbers, spaces, dots, hyphens, and underscores")
+	}
+	return nil
+}
+
 // ValidateEmail validates an email address format
 func ValidateEmail(email string) error {
 	if email == "" {
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -20,20 +21,62 @@ type Organization struct {
 }
 
 type Domain struct {
-	ID                uuid.UUID `json:"id" db:"id"`
-	OrgID             uuid.UUID `json:"orgId" db:"org_id"`
-	RootDomain        string    `json:"rootDomain" db:"root_domain"`
-	Verified          bool      `json:"verified" db:"verified"`
-	VerificationToken string    `json:"verificationToken" db:"verification_token"`
-	CreatedAt         time.Time `json:"createdAt" db:"created_at"`
+	ID                 uuid.UUID `json:"id" db:"id"`
+	OrgID              uuid.UUID `json:"orgId" db:"org_id"`
+	RootDomain         string    `json:"rootDomain" db:"root_domain"`
+	Verified           bool      `json:"verified" db:"verified"`
+	VerificationToken  string    `json:"verificationToken" db:"verification_token"`
+	VerificationMethod string    `json:"verificationMethod,omitempty" db:"verification_method"` // dns_txt, cname, http_file, html_meta, parent_domain
+	// Periodic re-verification: failures start a grace period, after which scans are suspended
+	VerificationCheckedAt *time.Time `json:"verificationCheckedAt,omitempty" db:"verification_checked_at"`
+	VerificationFailedAt  *time.Time `json:"verificationFailedAt,omitempty" db:"verification_failed_at"`
+	ScansSuspended        bool       `json:"scansSuspended" db:"scans_suspended"`
+	ScanProfile           string     `json:"scanProfile,omitempty" db:"scan_profile"` // Empty uses the default profile
+	CreatedAt             time.Time  `json:"createdAt" db:"created_at"`
 }
 
-type Asset struct {
+// ScopeRule includes or excludes subdomains, addresses or ports of a domain from scanning
+type ScopeRule struct {
 	ID        uuid.UUID `json:"id" db:"id"`
 	DomainID  uuid.UUID `json:"domainId" db:"domain_id"`
-	Subdomain string    `json:"subdomain" db:"subdomain"`
-	IPAddress string    `json:"ipAddress" db:"ip_address"`
-	LastSeen  time.Time `json:"lastSeen" db:"last_seen"`
+	RuleType  string    `json:"ruleType" db:"rule_type"` // include_subdomain, exclude_subdomain, exclude_cidr, exclude_port
+	Pattern   string    `json:"pattern" db:"pattern"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+// IPRange is a customer-owned network block scanned without DNS names
+type IPRange struct {
+	ID                    uuid.UUID  `json:"id" db:"id"`
+	OrgID                 uuid.UUID  `json:"orgId" db:"org_id"`
+	CIDR                  string     `json:"cidr" db:"cidr"`
+	ASN                   string     `json:"asn,omitempty" db:"asn"`
+	Verified              bool       `json:"verified" db:"verified"`
+	VerificationToken     string     `json:"verificationToken" db:"verification_token"`
+	VerificationCheckedAt *time.Time `json:"verificationCheckedAt,omitempty" db:"verification_checked_at"`
+	VerificationFailedAt  *time.Time `json:"verificationFailedAt,omitempty" db:"verification_failed_at"`
+	ScansSuspended        bool       `json:"scansSuspended" db:"scans_suspended"`
+	ScanProfile           string     `json:"scanProfile,omitempty" db:"scan_profile"` // Empty uses the default profile
+	CreatedAt             time.Time  `json:"createdAt" db:"created_at"`
+}
+
+type Asset struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	DomainID  uuid.UUID  `json:"domainId" db:"domain_id"`
+	RangeID   *uuid.UUID `json:"rangeId,omitempty" db:"range_id"` // Set instead of DomainID for IP range assets
+	Subdomain string     `json:"subdomain" db:"subdomain"`
+	IPAddress string     `json:"ipAddress" db:"ip_address"`
+	IPVersion int        `json:"ipVersion" db:"-"` // 4 or 6, derived from ip_address
+	PTR       string     `json:"ptr,omitempty" db:"ptr"`
+	Provider  string     `json:"provider,omitempty" db:"provider"` // CDN or hosting network owning the IP
+	CDN       bool       `json:"cdn" db:"cdn"`
+	LastSeen  time.Time  `json:"lastSeen" db:"last_seen"`
+}
+
+// Host groups the asset rows of one subdomain, which may resolve to many addresses
+type Host struct {
+	Subdomain string    `json:"subdomain"`
+	Addresses []string  `json:"addresses"`
+	LastSeen  time.Time `json:"lastSeen"`
 }
 
 type Service struct {
@@ -43,25 +86,104 @@ type Service struct {
 	Protocol    string    `json:"protocol" db:"protocol"`
 	Fingerprint string    `json:"fingerprint" db:"fingerprint"`
 	Technology  string    `json:"technology" db:"technology"`
+	TLSGrade    string    `json:"tlsGrade,omitempty" db:"tls_grade"` // A+ to F, empty for plaintext services
+	TLSVersions []string  `json:"tlsVersions,omitempty" db:"tls_versions"`
+	WeakCiphers []string  `json:"weakCiphers,omitempty" db:"tls_weak_ciphers"`
+	Product     string    `json:"product,omitempty" db:"product"`
+	Version     string    `json:"version,omitempty" db:"version"`
+	CPE         string    `json:"cpe,omitempty" db:"cpe"`
+	HTTPTitle   string    `json:"httpTitle,omitempty" db:"http_title"`
+	FaviconHash int32     `json:"faviconHash,omitempty" db:"favicon_hash"` // Shodan-compatible mmh3 hash
+	ContentHash string    `json:"contentHash,omitempty" db:"content_hash"` // SHA-256 of the HTTP body
+	// Final URL, status, response headers, redirect chain and TLS session of the HTTP fingerprint
+	HTTPDetails json.RawMessage `json:"httpDetails,omitempty" db:"http_details"`
+	// Technologies identified from the HTTP fingerprint, e.g. "Traefik 2.10.4"
+	Technologies []string `json:"technologies,omitempty" db:"technologies"`
+	// Set when a protocol probe read data without credentials
+	Unauthenticated bool `json:"unauthenticated,omitempty" db:"unauthenticated"`
+}
+
+// Certificate is the TLS certificate presented by a service
+type Certificate struct {
+	ID                 uuid.UUID `json:"id" db:"id"`
+	ServiceID          uuid.UUID `json:"serviceId" db:"service_id"`
+	Host               string    `json:"host,omitempty" db:"-"` // Subdomain or IP of the asset, filled on reads
+	Port               int       `json:"port,omitempty" db:"-"`
+	Subject            string    `json:"subject" db:"subject"`
+	Issuer             string    `json:"issuer" db:"issuer"`
+	NotBefore          time.Time `json:"notBefore" db:"not_before"`
+	NotAfter           time.Time `json:"notAfter" db:"not_after"`
+	KeyType            string    `json:"keyType" db:"key_type"`
+	KeyBits            int       `json:"keyBits" db:"key_bits"`
+	SignatureAlgorithm string    `json:"signatureAlgorithm" db:"signature_algorithm"`
+	SANs               []string  `json:"sans" db:"sans"`
+	SelfSigned         bool      `json:"selfSigned" db:"self_signed"`
+	ChainComplete      bool      `json:"chainComplete" db:"chain_complete"`
+	ChainError         string    `json:"chainError,omitempty" db:"chain_error"`
+	FingerprintSHA256  string    `json:"fingerprintSha256" db:"fingerprint_sha256"`
+	LastSeen           time.Time `json:"lastSeen" db:"last_seen"`
 }
 
 type Finding struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	ServiceID   uuid.UUID `json:"serviceId" db:"service_id"`
-	Type        string    `json:"type" db:"type"`
-	Severity    string    `json:"severity" db:"severity"`
-	Description string    `json:"description" db:"description"`
-	Remediation string    `json:"remediation" db:"remediation"`
-	FirstSeen   time.Time `json:"firstSeen" db:"first_seen"`
-	LastSeen    time.Time `json:"lastSeen" db:"last_seen"`
+	ID          uuid.UUID  `json:"id" db:"id"`
+	ServiceID   *uuid.UUID `json:"serviceId,omitempty" db:"service_id"` // Nil for DNS-level findings
+	DomainID    *uuid.UUID `json:"domainId,omitempty" db:"domain_id"`
+	RangeID     *uuid.UUID `json:"rangeId,omitempty" db:"range_id"`
+	Target      string     `json:"target,omitempty" db:"target"` // Hostname for findings not tied to a service
+	Type        string     `json:"type" db:"type"`
+	Severity    string     `json:"severity" db:"severity"`
+	Description string     `json:"description" db:"description"`
+	Remediation string     `json:"remediation" db:"remediation"`
+	Evidence    []string   `json:"evidence,omitempty" db:"evidence"`
+	FirstSeen   time.Time  `json:"firstSeen" db:"first_seen"`
+	LastSeen    time.Time  `json:"lastSeen" db:"last_seen"`
 }
 
 type ScanRun struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	DomainID    uuid.UUID  `json:"domainId" db:"domain_id"`
+	RangeID     *uuid.UUID `json:"rangeId,omitempty" db:"range_id"`
+	ScanProfile string     `json:"scanProfile" db:"scan_profile"`
+	Status      string     `json:"status" db:"status"`
+	StartedAt   time.Time  `json:"startedAt" db:"started_at"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty" db:"finished_at"`
+	// Tasks run in-process because the org's agents did not deliver them
+	AgentFallbacks int `json:"agentFallbacks" db:"agent_fallbacks"`
+}
+
+// PassiveCache holds the Certificate Transparency names known for a root domain
+type PassiveCache struct {
+	RootDomain string    `json:"rootDomain" db:"root_domain"`
+	Subdomains []string  `json:"subdomains" db:"subdomains"`
+	CTCursor   string    `json:"ctCursor" db:"ct_cursor"`
+	FetchedAt  time.Time `json:"fetchedAt" db:"fetched_at"`
+}
+
+// Agent is a remote scanner that pulls tasks for its org from the API
+type Agent struct {
 	ID         uuid.UUID  `json:"id" db:"id"`
-	DomainID   uuid.UUID  `json:"domainId" db:"domain_id"`
-	Status     string     `json:"status" db:"status"`
-	StartedAt  time.Time  `json:"startedAt" db:"started_at"`
-	FinishedAt *time.Time `json:"finishedAt,omitempty" db:"finished_at"`
+	OrgID      uuid.UUID  `json:"orgId" db:"org_id"`
+	Name       string     `json:"name" db:"name"`
+	Hostname   string     `json:"hostname,omitempty" db:"hostname"`
+	Version    string     `json:"version,omitempty" db:"version"`
+	Address    string     `json:"address,omitempty" db:"address"` // Where the agent last connected from
+	LastSeenAt *time.Time `json:"lastSeenAt,omitempty" db:"last_seen_at"`
+	CreatedAt  time.Time  `json:"createdAt" db:"created_at"`
+}
+
+// AgentTask is a unit of scan work queued for the agents of an org
+type AgentTask struct {
+	ID          uuid.UUID       `json:"id" db:"id"`
+	OrgID       uuid.UUID       `json:"orgId" db:"org_id"`
+	AgentID     *uuid.UUID      `json:"agentId,omitempty" db:"agent_id"`
+	Kind        string          `json:"kind" db:"kind"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Status      string          `json:"status" db:"status"` // 'pending', 'running', 'completed', 'failed', 'cancelled'
+	Result      json.RawMessage `json:"result,omitempty" db:"result"`
+	Error       string          `json:"error,omitempty" db:"error"`
+	CreatedAt   time.Time       `json:"createdAt" db:"created_at"`
+	ClaimedAt   *time.Time      `json:"claimedAt,omitempty" db:"claimed_at"`
+	CompletedAt *time.Time      `json:"completedAt,omitempty" db:"completed_at"`
 }
 
 type AuditLog struct {
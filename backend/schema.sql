@@ -40,6 +40,40 @@ CREATE TABLE IF NOT EXISTS domains (
     UNIQUE(org_id, root_domain)
 );
 
+-- How ownership was proven: 'dns_txt', 'cname', 'http_file', 'html_meta', 'parent_domain'
+ALTER TABLE domains ADD COLUMN IF NOT EXISTS verification_method TEXT;
+
+-- Periodic re-verification of ownership
+ALTER TABLE domains ADD COLUMN IF NOT EXISTS verification_checked_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE domains ADD COLUMN IF NOT EXISTS verification_failed_at TIMESTAMP WITH TIME ZONE; -- Start of the grace period
+ALTER TABLE domains ADD COLUMN IF NOT EXISTS scans_suspended BOOLEAN DEFAULT false NOT NULL;
+ALTER TABLE domains ADD COLUMN IF NOT EXISTS verification_warned_at TIMESTAMP WITH TIME ZONE; -- Last grace period warning sent
+
+-- Port scan profile selected for the domain (NULL uses the default profile)
+ALTER TABLE domains ADD COLUMN IF NOT EXISTS scan_profile TEXT;
+
+-- Scope Rules (per-domain include/exclude patterns enforced before any probe)
+CREATE TABLE IF NOT EXISTS scope_rules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    domain_id UUID REFERENCES domains(id) ON DELETE CASCADE,
+    rule_type TEXT NOT NULL, -- 'include_subdomain', 'exclude_subdomain', 'exclude_cidr', 'exclude_port'
+    pattern TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(domain_id, rule_type, pattern)
+);
+
+-- IP Ranges (customer-owned network blocks scanned without DNS names)
+CREATE TABLE IF NOT EXISTS ip_ranges (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    cidr CIDR NOT NULL,
+    asn TEXT,
+    verified BOOLEAN DEFAULT false NOT NULL,
+    verification_token TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(org_id, cidr)
+);
+
 -- Assets (Subdomains/IPs)
 CREATE TABLE IF NOT EXISTS assets (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -50,6 +84,24 @@ CREATE TABLE IF NOT EXISTS assets (
     UNIQUE(domain_id, subdomain, ip_address)
 );
 
+-- Reverse DNS and shared hosting annotations
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS ptr TEXT;
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS provider TEXT;
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS cdn BOOLEAN DEFAULT false NOT NULL;
+
+-- Assets seeded from an IP range have no domain
+-- Periodic re-verification of range ownership, as for domains
+ALTER TABLE ip_ranges ADD COLUMN IF NOT EXISTS verification_checked_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE ip_ranges ADD COLUMN IF NOT EXISTS verification_failed_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE ip_ranges ADD COLUMN IF NOT EXISTS verification_warned_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE ip_ranges ADD COLUMN IF NOT EXISTS scans_suspended BOOLEAN DEFAULT false NOT NULL;
+
+-- Port scan profile for range sweeps; empty uses the default profile
+ALTER TABLE ip_ranges ADD COLUMN IF NOT EXISTS scan_profile TEXT;
+
+ALTER TABLE assets ADD COLUMN IF NOT EXISTS range_id UUID REFERENCES ip_ranges(id) ON DELETE CASCADE;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_assets_range_ip ON assets(range_id, ip_address) WHERE range_id IS NOT NULL;
+
 -- Services (Open ports/Detected software)
 CREATE TABLE IF NOT EXISTS services (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -62,6 +114,47 @@ CREATE TABLE IF NOT EXISTS services (
     UNIQUE(asset_id, port, protocol)
 );
 
+-- TLS protocol and cipher assessment of TLS-speaking services
+ALTER TABLE services ADD COLUMN IF NOT EXISTS tls_grade TEXT; -- 'A+', 'A', 'B', 'C', 'D', 'F'
+ALTER TABLE services ADD COLUMN IF NOT EXISTS tls_versions TEXT[];
+ALTER TABLE services ADD COLUMN IF NOT EXISTS tls_weak_ciphers TEXT[];
+
+-- Results of protocol probes (Redis, MongoDB, PostgreSQL, etcd)
+ALTER TABLE services ADD COLUMN IF NOT EXISTS version TEXT;
+ALTER TABLE services ADD COLUMN IF NOT EXISTS unauthenticated BOOLEAN DEFAULT FALSE;
+
+-- Product identification from protocol probes and banner signatures
+ALTER TABLE services ADD COLUMN IF NOT EXISTS product TEXT;
+ALTER TABLE services ADD COLUMN IF NOT EXISTS cpe TEXT;
+
+-- HTTP fingerprint details used to pivot on identical deployments
+ALTER TABLE services ADD COLUMN IF NOT EXISTS http_title TEXT;
+ALTER TABLE services ADD COLUMN IF NOT EXISTS favicon_hash INTEGER;
+ALTER TABLE services ADD COLUMN IF NOT EXISTS content_hash TEXT;
+ALTER TABLE services ADD COLUMN IF NOT EXISTS http_details JSONB; -- Final URL, status, headers, redirects and TLS session
+
+-- All technologies matched by the signature database, most confident first
+ALTER TABLE services ADD COLUMN IF NOT EXISTS technologies TEXT[];
+
+-- TLS certificates presented by services (one per service, refreshed every scan)
+CREATE TABLE IF NOT EXISTS certificates (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    service_id UUID UNIQUE REFERENCES services(id) ON DELETE CASCADE,
+    subject TEXT,
+    issuer TEXT,
+    not_before TIMESTAMP WITH TIME ZONE,
+    not_after TIMESTAMP WITH TIME ZONE,
+    key_type TEXT, -- 'RSA', 'ECDSA', 'Ed25519'
+    key_bits INTEGER,
+    signature_algorithm TEXT,
+    sans TEXT[],
+    self_signed BOOLEAN DEFAULT false NOT NULL,
+    chain_complete BOOLEAN DEFAULT false NOT NULL,
+    chain_error TEXT,
+    fingerprint_sha256 TEXT,
+    last_seen TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
 -- Findings (Vulnerabilities/Misconfigurations)
 CREATE TABLE IF NOT EXISTS findings (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -74,6 +167,14 @@ CREATE TABLE IF NOT EXISTS findings (
     last_seen TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
+-- DNS-level findings (e.g. subdomain takeover) are attached to the domain instead of a service
+ALTER TABLE findings ADD COLUMN IF NOT EXISTS domain_id UUID REFERENCES domains(id) ON DELETE CASCADE;
+ALTER TABLE findings ADD COLUMN IF NOT EXISTS target TEXT;
+ALTER TABLE findings ADD COLUMN IF NOT EXISTS range_id UUID REFERENCES ip_ranges(id) ON DELETE CASCADE;
+
+-- What an active probe observed, e.g. repository names of an open registry
+ALTER TABLE findings ADD COLUMN IF NOT EXISTS evidence TEXT[];
+
 -- Scan Runs
 CREATE TABLE IF NOT EXISTS scan_runs (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -83,6 +184,11 @@ CREATE TABLE IF NOT EXISTS scan_runs (
     finished_at TIMESTAMP WITH TIME ZONE
 );
 
+ALTER TABLE scan_runs ADD COLUMN IF NOT EXISTS range_id UUID REFERENCES ip_ranges(id) ON DELETE CASCADE;
+ALTER TABLE scan_runs ADD COLUMN IF NOT EXISTS scan_profile TEXT;
+-- Tasks run in-process because the org's agents did not deliver them
+ALTER TABLE scan_runs ADD COLUMN IF NOT EXISTS agent_fallbacks INTEGER DEFAULT 0;
+
 -- Audit Logs for Legal Compliance
 CREATE TABLE IF NOT EXISTS audit_logs (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
@@ -102,10 +208,53 @@ CREATE TABLE IF NOT EXISTS failed_login_attempts (
     last_attempt TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Passive Discovery Cache (Certificate Transparency names per root domain, shared across orgs)
+CREATE TABLE IF NOT EXISTS passive_discovery_cache (
+    root_domain TEXT PRIMARY KEY,
+    subdomains TEXT[] DEFAULT '{}' NOT NULL,
+    ct_cursor TEXT, -- Last Cert Spotter issuance ID read, for incremental polling
+    fetched_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Scan Agents (remote scanners that authenticate with a token and pull tasks for their org)
+CREATE TABLE IF NOT EXISTS agents (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    token_hash TEXT UNIQUE NOT NULL, -- SHA-256 of the agent token; the token itself is shown once
+    hostname TEXT,
+    version TEXT,
+    address TEXT,
+    last_seen_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Agent Tasks (discovery, port scan and probe work handed to agents; results return as JSON)
+CREATE TABLE IF NOT EXISTS agent_tasks (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    org_id UUID REFERENCES organizations(id) ON DELETE CASCADE,
+    agent_id UUID REFERENCES agents(id) ON DELETE SET NULL,
+    kind TEXT NOT NULL, -- 'discovery', 'port_scan', 'probe'
+    payload JSONB NOT NULL,
+    status TEXT DEFAULT 'pending' NOT NULL, -- 'pending', 'running', 'completed', 'failed', 'cancelled'
+    result JSONB,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    claimed_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE
+);
+
 -- Indexes for Performance
 CREATE INDEX IF NOT EXISTS idx_domains_org_id ON domains(org_id);
+CREATE INDEX IF NOT EXISTS idx_scope_rules_domain_id ON scope_rules(domain_id);
+CREATE INDEX IF NOT EXISTS idx_ip_ranges_org_id ON ip_ranges(org_id);
 CREATE INDEX IF NOT EXISTS idx_assets_domain_id ON assets(domain_id);
 CREATE INDEX IF NOT EXISTS idx_services_asset_id ON services(asset_id);
+CREATE INDEX IF NOT EXISTS idx_certificates_not_after ON certificates(not_after);
 CREATE INDEX IF NOT EXISTS idx_findings_service_id ON findings(service_id);
+CREATE INDEX IF NOT EXISTS idx_findings_domain_id ON findings(domain_id);
+CREATE INDEX IF NOT EXISTS idx_findings_range_id ON findings(range_id);
 CREATE INDEX IF NOT EXISTS idx_scan_runs_domain_id ON scan_runs(domain_id);
-CREATE INDEX IF NOT EXISTS idx_failed_login_attempts_user_id ON failed_login_attempts(user_id);
\ No newline at end of file
+CREATE INDEX IF NOT EXISTS idx_failed_login_attempts_user_id ON failed_login_attempts(user_id);
+CREATE INDEX IF NOT EXISTS idx_agents_org_id ON agents(org_id);
+CREATE INDEX IF NOT EXISTS idx_agent_tasks_org_status ON agent_tasks(org_id, status);
\ No newline at end of file